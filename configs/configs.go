@@ -7,17 +7,28 @@ import (
 	"time"
 
 	"github.com/lomik/zapwriter"
+
+	"github.com/Civil/github2telegram/semver"
+	"github.com/Civil/github2telegram/types"
 )
 
+// FilterRule includes or excludes releases that were matched by FilterRegex
+type FilterRule struct {
+	Exclude   bool
+	MatchBody bool
+	Regex     *regexp.Regexp
+}
+
 type FiltersConfig struct {
 	Name           string
 	Filter         string
 	MessagePattern string
 
-	FilterRegex     *regexp.Regexp
-	FilterProcessed bool
-	LastUpdateTime  time.Time
-	LastTag         string
+	FilterRegex    *regexp.Regexp
+	VersionFilter  *semver.Filter
+	Rules          []FilterRule
+	LastUpdateTime time.Time
+	LastTag        string
 }
 
 type NotificationConfig struct {
@@ -26,16 +37,18 @@ type NotificationConfig struct {
 	WebhookURL           string
 	WebhookPath          string
 	WebhookListenAddress string
+	// ParseMode is the markup of the notifications: markdownv2 (default), html or plain
+	ParseMode string
 }
 
 type NotificationEndpoints interface {
-	Send(url, filter, message string) error
+	Send(update *types.Update) error
 	Process()
 }
 
 type FeedsConfig struct {
 	Repo    string
-	Filters []FiltersConfig
+	Filters []*FiltersConfig
 
 	PollingInterval time.Duration
 	Notifications   []string
@@ -52,15 +65,18 @@ var DefaultLoggerConfig = zapwriter.Config{
 
 type Configuration struct {
 	sync.RWMutex
-	Listen           string                        `yaml:"listen"`
-	Logger           []zapwriter.Config            `yaml:"logger"`
-	DatabaseType     string                        `yaml:"database_type"`
-	DatabaseURL      string                        `yaml:"database_url"`
-	DatabaseLogin    string                        `yaml:"database_login"`
-	DatabasePassword string                        `yaml:"database_password"`
-	AdminUsername    string                        `yaml:"admin_username"`
-	PollingInterval  time.Duration                 `yaml:"polling_interval"`
-	Endpoints        map[string]NotificationConfig `yaml:"endpoints"`
+	Listen             string                        `yaml:"listen"`
+	Logger             []zapwriter.Config            `yaml:"logger"`
+	DatabaseType       string                        `yaml:"database_type"`
+	DatabaseURL        string                        `yaml:"database_url"`
+	DatabaseLogin      string                        `yaml:"database_login"`
+	DatabasePassword   string                        `yaml:"database_password"`
+	AdminUsername      string                        `yaml:"admin_username"` // deprecated, usernames can change, use Admins
+	Admins             []int64                       `yaml:"admins"`         // user IDs of the bot's administrators
+	FeedManagers       []int64                       `yaml:"feed_managers"`  // user IDs of users who can manage feeds
+	PollingInterval    time.Duration                 `yaml:"polling_interval"`
+	MaxReleasesPerPoll int                           `yaml:"max_releases_per_poll"`
+	Endpoints          map[string]NotificationConfig `yaml:"endpoints"`
 
 	DB              *sql.DB                          `yaml:"-"`
 	Senders         map[string]NotificationEndpoints `yaml:"-"`
@@ -70,13 +86,13 @@ type Configuration struct {
 }
 
 var Config = Configuration{
-	AdminUsername:   "REPLACE_ME",
-	Listen:          "127.0.0.1:8080",
-	Logger:          []zapwriter.Config{DefaultLoggerConfig},
-	DatabaseType:    "sqlite3",
-	DatabaseURL:     "./github2telegram.DB",
-	PollingInterval: 5 * time.Minute,
-	ProcessingFeeds: make(map[string]bool),
+	Listen:             "127.0.0.1:8080",
+	Logger:             []zapwriter.Config{DefaultLoggerConfig},
+	DatabaseType:       "sqlite3",
+	DatabaseURL:        "./github2telegram.DB",
+	PollingInterval:    5 * time.Minute,
+	MaxReleasesPerPoll: 5,
+	ProcessingFeeds:    make(map[string]bool),
 }
 
 func (c *Configuration) GetDB() *sql.DB {
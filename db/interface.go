@@ -13,11 +13,24 @@ type Database interface {
 	AddFeed(name, repo, filter, messagePattern string) (int, error)
 	GetFeed(name string) (*Feed, error)
 	ListFeeds() ([]*Feed, error)
+	SetFeedVersionFilter(name, repo, versionFilter string) error
+	SetFeedMessagePattern(name, repo, messagePattern string) error
+	SetFeedFilter(name, repo, url, filter string) error
+
+	// Include and exclude rules of the filter
+	ListFilterRules(repo, name string) ([]*FilterRule, error)
+	SetFilterRules(repo, name string, rules []*FilterRule) error
 	RemoveFeed(name, repo, filter, messagePattern string) error
 
+	// Releases that were already seen in the feed
+	GetSeenReleases(url string) ([]*SeenRelease, error)
+	MarkReleaseSeen(url string, release *SeenRelease) error
+
 	// Subscriptions
-	AddSubscribtion(endpoint, url, filter string, chatID int64) error
+	AddSubscribtion(endpoint, url, filter string, chatID int64, threadID int) error
 	RemoveSubscribtion(endpoint, url, filter string, chatID int64) error
+	MoveSubscriptions(url, filter, newFilter string) error
+	RemoveSubscriptions(url, filter string) error
 
 	// Maintenance
 	UpdateChatID(oldChatID, newChatID int64) error
@@ -27,6 +40,29 @@ type Database interface {
 
 	// Endpoints
 	GetEndpointInfo(endpoint, url, filter string) ([]int64, error)
+	GetSubscriptions(endpoint, url, filter string) ([]*Subscription, error)
+	GetSubscription(endpoint, url, filter string, chatID int64) (*Subscription, error)
+	GetSubscriptionByID(id int64) (*Subscription, error)
+	GetChatSubscriptions(endpoint string, chatID int64) ([]*Subscription, error)
+	MuteRepo(endpoint, url string, chatID int64, until time.Time) error
+	ResetThread(chatID int64, threadID int) error
+	UpdateSubscriptionSettings(sub *Subscription) error
+
+	// State of multi-step commands
+	GetConversation(chatID, userID int64) (*Conversation, error)
+	SaveConversation(c *Conversation) error
+	RemoveConversation(chatID, userID int64) error
+
+	// Roles granted to users
+	GetRoles() ([]*Role, error)
+	SetRole(r *Role) error
+	RemoveRole(userID int64) error
+
+	// Chats the bot was added to
+	GetChat(id int64) (*Chat, error)
+	ListChats() ([]*Chat, error)
+	SaveChat(c *Chat) error
+	RemoveChatSubscriptions(endpoint string, chatID int64) (int64, error)
 
 	// Resend Queue
 	AddMessagesToResentQueue(messages []*types.NotificationMessage) error
@@ -39,4 +75,118 @@ type Feed struct {
 	Filter         string
 	Name           string
 	MessagePattern string
+	VersionFilter  string
+	Rules          []*FilterRule
+}
+
+const (
+	RuleActionInclude = "include"
+	RuleActionExclude = "exclude"
+
+	RuleFieldTitle = "title"
+	RuleFieldBody  = "body"
+)
+
+// FilterRule is an additional include or exclude pattern of the filter. Rules are applied in order
+type FilterRule struct {
+	Action  string
+	Field   string
+	Pattern string
+}
+
+// SeenRelease is a release that was already processed. ReleaseID is a unique id of the release in the feed (e.x. atom
+// entry id), ContentHash changes when release is edited
+type SeenRelease struct {
+	ReleaseID   string
+	Tag         string
+	Title       string
+	Link        string
+	ContentHash string
+	Updated     time.Time
+	// Content is the release notes, kept to show what was changed when release is edited
+	Content string
+	Status  string
+	// CheckedAt is when release that disappeared from the feed was verified to still exist, zero if it wasn't
+	CheckedAt time.Time
+}
+
+const (
+	ReleaseStatusYanked  = "yanked"
+	ReleaseStatusDeleted = "deleted"
+)
+
+// Conversation is the state of multi-step command that user runs in the chat. Data holds answers given on previous
+// steps.
+type Conversation struct {
+	ChatID    int64
+	UserID    int64
+	Command   string
+	Step      string
+	Data      map[string]string
+	ExpiresAt time.Time
+}
+
+// Role is a role granted to Telegram user
+type Role struct {
+	UserID  int64
+	Role    string
+	AddedBy int64
+	AddedAt time.Time
+}
+
+// Chat is a chat the bot was added to. Status is the bot's membership status reported by Telegram, e.x. "member",
+// "administrator", "left" or "kicked".
+type Chat struct {
+	ID       int64
+	Type     string
+	Title    string
+	Username string
+	AddedBy  int64
+	AddedAt  time.Time
+	Status   string
+}
+
+type Subscription struct {
+	ID       int64
+	Endpoint string
+	Url      string
+	Filter   string
+	ChatID   int64
+
+	// Channels subscription wants to receive, empty means all of them
+	Channels []types.Channel
+	// SilentChannels are delivered without notification
+	SilentChannels []types.Channel
+	// NotifyDescriptionChanges enables notifications about edited release notes
+	NotifyDescriptionChanges bool
+	// Template overrides message template of the feed, empty means feed's one
+	Template string
+	// NotesLength is the maximum length of release notes, 0 means default and negative value means as much as fits
+	NotesLength int
+	// NotesMode defines what happens with notes that are too long: truncate (default), split or document
+	NotesMode string
+	// MutedUntil is the time notifications are muted until
+	MutedUntil time.Time
+	// ThreadID is the forum topic notifications are sent to, 0 means general topic or chat without topics
+	ThreadID int
+}
+
+// Accepts returns true if release from one of the channels should be delivered to the subscription and whether it
+// should be delivered silently, which is the case only if all accepted channels are silent
+func (s *Subscription) Accepts(channels []types.Channel) (deliver, silent bool) {
+	if len(channels) == 0 {
+		// Release wasn't classified
+		return true, false
+	}
+	silent = true
+	for _, c := range channels {
+		if len(s.Channels) > 0 && !types.HasChannel(s.Channels, c) {
+			continue
+		}
+		deliver = true
+		if !types.HasChannel(s.SilentChannels, c) {
+			silent = false
+		}
+	}
+	return deliver, deliver && silent
 }
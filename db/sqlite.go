@@ -2,6 +2,8 @@ package db
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/Civil/github2telegram/types"
 	"time"
@@ -12,9 +14,15 @@ import (
 )
 
 const (
-	currentSchemaVersion = 3
+	currentSchemaVersion = 19
 )
 
+// seedChatsQuery adds chats that have subscriptions to the chat registry. Their details are unknown until the bot
+// gets an update from the chat, only private chats can be told by their id.
+const seedChatsQuery = `
+INSERT OR IGNORE INTO 'chats' (id, type, status)
+	SELECT DISTINCT chat_id, CASE WHEN chat_id > 0 THEN 'private' ELSE 'unknown' END, 'member' FROM 'subscriptions';`
+
 type SQLite struct {
 	db *sql.DB
 }
@@ -57,7 +65,15 @@ func initSqlite() Database {
 						'chat_id' Int64,
 						'endpoint' VARCHAR(255) NOT NULL,
 						'url' VARCHAR(255) NOT NULL,
-						'filter' VARCHAR(255) NOT NULL
+						'filter' VARCHAR(255) NOT NULL,
+						'channels' VARCHAR(255) NOT NULL DEFAULT '',
+						'silent_channels' VARCHAR(255) NOT NULL DEFAULT '',
+						'notify_description_changes' BOOLEAN NOT NULL DEFAULT 1,
+						'template' TEXT NOT NULL DEFAULT '',
+						'notes_length' INTEGER NOT NULL DEFAULT 0,
+						'notes_mode' VARCHAR(16) NOT NULL DEFAULT '',
+						'muted_until' INTEGER NOT NULL DEFAULT 0,
+						'thread_id' INTEGER NOT NULL DEFAULT 0
 					);
 
 					CREATE TABLE IF NOT EXISTS 'feeds' (
@@ -65,16 +81,73 @@ func initSqlite() Database {
 						'repo' VARCHAR(255) NOT NULL,
 						'filter' VARCHAR(255) NOT NULL,
 						'name' VARCHAR(255) NOT NULL,
-						'message_pattern' VARCHAR(255) NOT NULL
+						'message_pattern' VARCHAR(255) NOT NULL,
+						'version_filter' VARCHAR(255) NOT NULL DEFAULT ''
 					);
 
 					CREATE TABLE IF NOT EXISTS 'resend_queue' (
 					    'id' INTEGER PRIMARY KEY AUTOINCREMENT,
                         'chat_id' Int64,
-                        'message' TEXT NOT NULL
+                        'message' TEXT NOT NULL,
+                        'silent' BOOLEAN NOT NULL DEFAULT 0,
+                        'fallback' TEXT NOT NULL DEFAULT '',
+                        'buttons' TEXT NOT NULL DEFAULT '',
+                        'thread_id' INTEGER NOT NULL DEFAULT 0
+					);
+
+					CREATE TABLE IF NOT EXISTS 'filter_rules' (
+						'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+						'repo' VARCHAR(255) NOT NULL,
+						'name' VARCHAR(255) NOT NULL,
+						'position' INTEGER NOT NULL,
+						'action' VARCHAR(16) NOT NULL,
+						'field' VARCHAR(16) NOT NULL,
+						'pattern' VARCHAR(255) NOT NULL
+					);
+
+					CREATE TABLE IF NOT EXISTS 'seen_releases' (
+						'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+						'url' VARCHAR(255) NOT NULL,
+						'release_id' VARCHAR(255) NOT NULL,
+						'tag' VARCHAR(255) NOT NULL,
+						'title' VARCHAR(255) NOT NULL,
+						'link' VARCHAR(255) NOT NULL,
+						'content_hash' VARCHAR(64) NOT NULL,
+						'updated' DATE NOT NULL,
+						'content' TEXT NOT NULL DEFAULT '',
+						'status' VARCHAR(16) NOT NULL DEFAULT '',
+						'checked_at' INTEGER NOT NULL DEFAULT 0,
+						UNIQUE (url, release_id)
+					);
+
+					CREATE TABLE IF NOT EXISTS 'conversations' (
+						'chat_id' INTEGER NOT NULL,
+						'user_id' INTEGER NOT NULL,
+						'command' VARCHAR(64) NOT NULL,
+						'step' VARCHAR(64) NOT NULL,
+						'data' TEXT NOT NULL,
+						'expires_at' INTEGER NOT NULL,
+						PRIMARY KEY (chat_id, user_id)
+					);
+
+					CREATE TABLE IF NOT EXISTS 'roles' (
+						'user_id' INTEGER PRIMARY KEY,
+						'role' VARCHAR(32) NOT NULL,
+						'added_by' INTEGER NOT NULL,
+						'added_at' INTEGER NOT NULL
 					);
 
-					INSERT INTO 'schema_version' (id, version) values (1, 3);
+					CREATE TABLE IF NOT EXISTS 'chats' (
+						'id' INTEGER PRIMARY KEY,
+						'type' VARCHAR(16) NOT NULL,
+						'title' VARCHAR(255) NOT NULL DEFAULT '',
+						'username' VARCHAR(255) NOT NULL DEFAULT '',
+						'added_by' INTEGER NOT NULL DEFAULT 0,
+						'added_at' INTEGER NOT NULL DEFAULT 0,
+						'status' VARCHAR(16) NOT NULL
+					);
+
+					INSERT INTO 'schema_version' (id, version) values (1, 19);
 				`)
 			if err != nil {
 				logger.Fatal("failed to initialize database",
@@ -155,74 +228,1168 @@ UPDATE schema_version SET version = 3 WHERE id=1;`)
 			schemaVersion = 3
 		}
 
-		if schemaVersion != currentSchemaVersion {
-			// Don't know how to migrate from this version
-			logger.Fatal("Unknown schema version specified",
-				zap.Int("version", schemaVersion),
-			)
+		if schemaVersion == 3 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE feeds ADD COLUMN 'version_filter' VARCHAR(255) NOT NULL DEFAULT '';`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 4 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 4.
+			schemaVersion = 4
 		}
-	}
 
-	return db
-}
+		if schemaVersion == 4 {
+			_, err = configs.Config.DB.Exec(`	CREATE TABLE IF NOT EXISTS 'filter_rules' (
+						'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+						'repo' VARCHAR(255) NOT NULL,
+						'name' VARCHAR(255) NOT NULL,
+						'position' INTEGER NOT NULL,
+						'action' VARCHAR(16) NOT NULL,
+						'field' VARCHAR(16) NOT NULL,
+						'pattern' VARCHAR(255) NOT NULL
+					);`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
 
-func NewSQLite() Database {
-	return initSqlite()
-}
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 5 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
 
-var ErrAlreadyExists error = fmt.Errorf("already exists")
+			// We've successfully upgraded to schema version 5.
+			schemaVersion = 5
+		}
 
-// GetLastUpdateTime - gets Last Update Time
-func (d *SQLite) GetLastUpdateTime(url, filter string) time.Time {
-	t, _ := time.Parse("2006-01-02", "1970-01-01")
-	logger := zapwriter.Logger("get_date")
-	stmt, err := d.db.Prepare("SELECT date from 'last_version' where url=? and filter=?")
-	if err != nil {
-		logger.Error("error creating statement",
-			zap.Error(err),
-		)
-		return t
-	}
-	rows, err := stmt.Query(url, filter)
-	if err != nil {
-		logger.Error("error retrieving data",
-			zap.Error(err),
-		)
-		return t
-	}
-	for rows.Next() {
-		err = rows.Scan(&t)
-		if err != nil {
-			logger.Error("error retrieving data",
-				zap.Error(err),
-			)
-			break
+		if schemaVersion == 5 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE subscriptions ADD COLUMN 'channels' VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE subscriptions ADD COLUMN 'silent_channels' VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE resend_queue ADD COLUMN 'silent' BOOLEAN NOT NULL DEFAULT 0;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 6 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 6.
+			schemaVersion = 6
 		}
-	}
-	_ = rows.Close()
-	return t
+
+		if schemaVersion == 6 {
+			_, err = configs.Config.DB.Exec(`	CREATE TABLE IF NOT EXISTS 'seen_releases' (
+						'id' INTEGER PRIMARY KEY AUTOINCREMENT,
+						'url' VARCHAR(255) NOT NULL,
+						'release_id' VARCHAR(255) NOT NULL,
+						'tag' VARCHAR(255) NOT NULL,
+						'title' VARCHAR(255) NOT NULL,
+						'link' VARCHAR(255) NOT NULL,
+						'content_hash' VARCHAR(64) NOT NULL,
+						'updated' DATE NOT NULL,
+						UNIQUE (url, release_id)
+					);`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 7 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 7.
+			schemaVersion = 7
+		}
+
+		if schemaVersion == 7 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE seen_releases ADD COLUMN 'content' TEXT NOT NULL DEFAULT '';
+ALTER TABLE subscriptions ADD COLUMN 'notify_description_changes' BOOLEAN NOT NULL DEFAULT 1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 8 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 8.
+			schemaVersion = 8
+		}
+
+		if schemaVersion == 8 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE seen_releases ADD COLUMN 'status' VARCHAR(16) NOT NULL DEFAULT '';`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 9 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 9.
+			schemaVersion = 9
+		}
+
+		if schemaVersion == 9 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE subscriptions ADD COLUMN 'template' TEXT NOT NULL DEFAULT '';`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 10 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 10.
+			schemaVersion = 10
+		}
+
+		if schemaVersion == 10 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE resend_queue ADD COLUMN 'fallback' TEXT NOT NULL DEFAULT '';`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 11 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 11.
+			schemaVersion = 11
+		}
+
+		if schemaVersion == 11 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE subscriptions ADD COLUMN 'notes_length' INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE subscriptions ADD COLUMN 'notes_mode' VARCHAR(16) NOT NULL DEFAULT '';`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 12 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 12.
+			schemaVersion = 12
+		}
+
+		if schemaVersion == 12 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE subscriptions ADD COLUMN 'muted_until' INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE resend_queue ADD COLUMN 'buttons' TEXT NOT NULL DEFAULT '';`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 13 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 13.
+			schemaVersion = 13
+		}
+
+		if schemaVersion == 13 {
+			_, err = configs.Config.DB.Exec(`	CREATE TABLE IF NOT EXISTS 'conversations' (
+						'chat_id' INTEGER NOT NULL,
+						'user_id' INTEGER NOT NULL,
+						'command' VARCHAR(64) NOT NULL,
+						'step' VARCHAR(64) NOT NULL,
+						'data' TEXT NOT NULL,
+						'expires_at' INTEGER NOT NULL,
+						PRIMARY KEY (chat_id, user_id)
+					);`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 14 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 14.
+			schemaVersion = 14
+		}
+
+		if schemaVersion == 14 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE subscriptions ADD COLUMN 'thread_id' INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE resend_queue ADD COLUMN 'thread_id' INTEGER NOT NULL DEFAULT 0;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 15 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 15.
+			schemaVersion = 15
+		}
+
+		if schemaVersion == 15 {
+			_, err = configs.Config.DB.Exec(`	CREATE TABLE IF NOT EXISTS 'roles' (
+						'user_id' INTEGER PRIMARY KEY,
+						'role' VARCHAR(32) NOT NULL,
+						'added_by' INTEGER NOT NULL,
+						'added_at' INTEGER NOT NULL
+					);`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 16 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 16.
+			schemaVersion = 16
+		}
+
+		if schemaVersion == 16 {
+			_, err = configs.Config.DB.Exec(`	CREATE TABLE IF NOT EXISTS 'chats' (
+						'id' INTEGER PRIMARY KEY,
+						'type' VARCHAR(16) NOT NULL,
+						'title' VARCHAR(255) NOT NULL DEFAULT '',
+						'username' VARCHAR(255) NOT NULL DEFAULT '',
+						'added_by' INTEGER NOT NULL DEFAULT 0,
+						'added_at' INTEGER NOT NULL DEFAULT 0,
+						'status' VARCHAR(16) NOT NULL
+					);`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 17 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 17.
+			schemaVersion = 17
+		}
+
+		if schemaVersion == 17 {
+			_, err = configs.Config.DB.Exec(`
+ALTER TABLE seen_releases ADD COLUMN 'checked_at' INTEGER NOT NULL DEFAULT 0;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 18 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 18.
+			schemaVersion = 18
+		}
+
+		if schemaVersion == 18 {
+			_, err = configs.Config.DB.Exec(seedChatsQuery)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			_, err = configs.Config.DB.Exec(`
+UPDATE schema_version SET version = 19 WHERE id=1;`)
+			if err != nil {
+				logger.Fatal("failed to migrate database",
+					zap.Int("databaseVersion", schemaVersion),
+					zap.Int("upgradingTo", currentSchemaVersion),
+					zap.Error(err),
+				)
+			}
+
+			// We've successfully upgraded to schema version 19.
+			schemaVersion = 19
+		}
+
+		if schemaVersion != currentSchemaVersion {
+			// Don't know how to migrate from this version
+			logger.Fatal("Unknown schema version specified",
+				zap.Int("version", schemaVersion),
+			)
+		}
+	}
+
+	return db
+}
+
+func NewSQLite() Database {
+	return initSqlite()
+}
+
+var ErrAlreadyExists error = fmt.Errorf("already exists")
+var ErrNotFound error = fmt.Errorf("not found")
+
+// GetLastUpdateTime - gets Last Update Time
+func (d *SQLite) GetLastUpdateTime(url, filter string) time.Time {
+	t, _ := time.Parse("2006-01-02", "1970-01-01")
+	logger := zapwriter.Logger("get_date")
+	stmt, err := d.db.Prepare("SELECT date from 'last_version' where url=? and filter=?")
+	if err != nil {
+		logger.Error("error creating statement",
+			zap.Error(err),
+		)
+		return t
+	}
+	rows, err := stmt.Query(url, filter)
+	if err != nil {
+		logger.Error("error retrieving data",
+			zap.Error(err),
+		)
+		return t
+	}
+	for rows.Next() {
+		err = rows.Scan(&t)
+		if err != nil {
+			logger.Error("error retrieving data",
+				zap.Error(err),
+			)
+			break
+		}
+	}
+	_ = rows.Close()
+	return t
+}
+
+// GetLastTag - gets Last Tag
+func (d *SQLite) GetLastTag(url, filter string) string {
+	var tag string
+	logger := zapwriter.Logger("get_last_tag")
+	stmt, err := d.db.Prepare("SELECT last_tag from 'last_version' where url=? and filter=?")
+	if err != nil {
+		logger.Error("error creating statement",
+			zap.Error(err),
+		)
+		return tag
+	}
+	rows, err := stmt.Query(url, filter)
+	if err != nil {
+		logger.Error("error retrieving data",
+			zap.Error(err),
+		)
+		return tag
+	}
+	for rows.Next() {
+		err = rows.Scan(&tag)
+		if err != nil {
+			logger.Error("error retrieving data",
+				zap.Error(err),
+			)
+			break
+		}
+	}
+	_ = rows.Close()
+	return tag
+}
+
+func (d *SQLite) AddFeed(name, repo, filter, messagePattern string) (int, error) {
+	stmt, err := d.db.Prepare("SELECT id FROM 'feeds' where name=? and repo=?;")
+	if err != nil {
+		return -1, err
+	}
+
+	rows, err := stmt.Query(name, repo)
+	if err != nil {
+		return -1, err
+	}
+
+	var id int
+	if rows.Next() {
+		err = rows.Scan(&id)
+		if err != nil {
+			return -1, err
+		}
+		_ = rows.Close()
+		return id, ErrAlreadyExists
+	}
+	_ = rows.Close()
+
+	stmt, err = d.db.Prepare("INSERT INTO 'feeds' (name, repo, filter, message_pattern) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return -1, err
+	}
+
+	_, err = stmt.Exec(name, repo, filter, messagePattern)
+	if err != nil {
+		return -1, err
+	}
+
+	stmt, err = d.db.Prepare("SELECT id FROM 'feeds' where name=? and repo=?;")
+	if err != nil {
+		return -1, err
+	}
+
+	rows, err = stmt.Query(name, repo)
+	if err != nil {
+		return -1, err
+	}
+
+	if rows.Next() {
+		err = rows.Scan(&id)
+		if err != nil {
+			return -1, err
+		}
+	}
+	_ = rows.Close()
+
+	return id, nil
+}
+
+func (d *SQLite) GetFeed(name string) (*Feed, error) {
+	stmt, err := d.db.Prepare("SELECT name, repo, filter, message_pattern, version_filter FROM 'feeds' WHERE name=?;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Feed{}
+	for rows.Next() {
+		err = rows.Scan(&result.Name, &result.Repo, &result.Filter, &result.MessagePattern, &result.VersionFilter)
+		if err != nil {
+			continue
+		}
+	}
+	_ = rows.Close()
+
+	return result, nil
+}
+
+func (d *SQLite) ListFeeds() ([]*Feed, error) {
+	rows, err := d.db.Query("SELECT id, name, repo, filter, message_pattern, version_filter FROM 'feeds';")
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*Feed
+	for rows.Next() {
+		f := &Feed{}
+		err = rows.Scan(&f.Id, &f.Name, &f.Repo, &f.Filter, &f.MessagePattern, &f.VersionFilter)
+		if err != nil {
+			continue
+		}
+
+		result = append(result, f)
+	}
+	_ = rows.Close()
+
+	for _, f := range result {
+		f.Rules, err = d.ListFilterRules(f.Repo, f.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func (d *SQLite) ListFilterRules(repo, name string) ([]*FilterRule, error) {
+	stmt, err := d.db.Prepare("SELECT action, field, pattern FROM 'filter_rules' WHERE repo=? and name=? ORDER BY position;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(repo, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*FilterRule
+	for rows.Next() {
+		r := &FilterRule{}
+		err = rows.Scan(&r.Action, &r.Field, &r.Pattern)
+		if err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	_ = rows.Close()
+
+	return result, nil
+}
+
+// SetFilterRules replaces all rules of the filter
+func (d *SQLite) SetFilterRules(repo, name string, rules []*FilterRule) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM 'filter_rules' WHERE repo=? and name=?", repo, name)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for i, r := range rules {
+		_, err = tx.Exec("INSERT INTO 'filter_rules' (repo, name, position, action, field, pattern) VALUES (?, ?, ?, ?, ?, ?)",
+			repo, name, i, r.Action, r.Field, r.Pattern)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (d *SQLite) SetFeedVersionFilter(name, repo, versionFilter string) error {
+	stmt, err := d.db.Prepare("UPDATE 'feeds' SET version_filter=? WHERE name=? and repo=?")
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(versionFilter, name, repo)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// SetFeedFilter replaces regexp of the feed. Last announced version is stored by feed url and regexp, so it's moved
+// to the new regexp as well, or copied if another filter of the repo still uses the old one.
+func (d *SQLite) SetFeedFilter(name, repo, url, filter string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var oldFilter string
+	err = tx.QueryRow("SELECT filter FROM 'feeds' WHERE name=? and repo=?", name, repo).Scan(&oldFilter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE 'feeds' SET filter=? WHERE name=? and repo=?", filter, name, repo)
+	if err != nil {
+		return err
+	}
+	if oldFilter == filter {
+		return tx.Commit()
+	}
+
+	var shared, existing int
+	err = tx.QueryRow("SELECT COUNT(*) FROM 'feeds' WHERE repo=? and filter=? and name!=?", repo, oldFilter, name).Scan(&shared)
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow("SELECT COUNT(*) FROM 'last_version' WHERE url=? and filter=?", url, filter).Scan(&existing)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case existing > 0 && shared == 0:
+		// Another filter already uses the new regexp, its state is kept
+		_, err = tx.Exec("DELETE FROM 'last_version' WHERE url=? and filter=?", url, oldFilter)
+	case existing > 0:
+	case shared == 0:
+		_, err = tx.Exec("UPDATE 'last_version' SET filter=? WHERE url=? and filter=?", filter, url, oldFilter)
+	default:
+		_, err = tx.Exec(`INSERT INTO 'last_version' (url, filter, date, last_tag)
+			SELECT url, ?, date, last_tag FROM 'last_version' WHERE url=? and filter=?`, filter, url, oldFilter)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *SQLite) SetFeedMessagePattern(name, repo, messagePattern string) error {
+	stmt, err := d.db.Prepare("UPDATE 'feeds' SET message_pattern=? WHERE name=? and repo=?")
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(messagePattern, name, repo)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (d *SQLite) RemoveFeed(name, repo, filter, messagePattern string) error {
+	logger := zapwriter.Logger("remove_feed")
+	stmt, err := d.db.Prepare("DELETE FROM 'feeds' WHERE name=? and repo=? and filter=? and message_pattern=?")
+	if err != nil {
+		logger.Error("error creating statement",
+			zap.Error(err),
+		)
+		return err
+	}
+
+	_, err = stmt.Exec(name, repo, filter, messagePattern)
+	if err != nil {
+		logger.Error("error removing subscription",
+			zap.Error(err),
+		)
+	}
+
+	return err
+}
+
+func (d *SQLite) UpdateChatID(oldChatID, newChatID int64) error {
+	stmt, err := d.db.Prepare("UPDATE 'subscriptions' SET chat_id=? WHERE chat_id=?")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(newChatID, oldChatID)
+	if err != nil {
+		return err
+	}
+
+	// Registry might already know the new chat, in that case the old record is removed
+	stmt, err = d.db.Prepare("UPDATE OR IGNORE 'chats' SET id=? WHERE id=?")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(newChatID, oldChatID)
+	if err != nil {
+		return err
+	}
+
+	stmt, err = d.db.Prepare("DELETE FROM 'chats' WHERE id=?")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(oldChatID)
+	return err
+}
+
+func (d *SQLite) AddSubscribtion(endpoint, url, filter string, chatID int64, threadID int) error {
+	stmt, err := d.db.Prepare("SELECT chat_id FROM 'subscriptions' where endpoint=? and url=? and filter=? and chat_id=?;")
+	if err != nil {
+		return err
+	}
+
+	rows, err := stmt.Query(endpoint, url, filter, chatID)
+	if err != nil {
+		return err
+	}
+
+	if rows.Next() {
+		_ = rows.Close()
+		return ErrAlreadyExists
+	}
+	_ = rows.Close()
+
+	stmt, err = d.db.Prepare("INSERT INTO 'subscriptions' (endpoint, url, filter, chat_id, thread_id) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(endpoint, url, filter, chatID, threadID)
+
+	return err
+}
+
+func (d *SQLite) RemoveSubscribtion(endpoint, url, filter string, chatID int64) error {
+	stmt, err := d.db.Prepare("DELETE FROM 'subscriptions' WHERE endpoint=? and url=? and filter=? and chat_id=?")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(endpoint, url, filter, chatID)
+
+	return err
+}
+
+// MoveSubscriptions moves all subscriptions of the filter to another filter of the same repo. Chats that are already
+// subscribed to both keep the existing subscription to the target filter.
+func (d *SQLite) MoveSubscriptions(url, filter, newFilter string) error {
+	stmt, err := d.db.Prepare(`DELETE FROM 'subscriptions' WHERE url=? and filter=? and EXISTS (
+		SELECT 1 FROM 'subscriptions' s WHERE s.endpoint=subscriptions.endpoint and s.url=subscriptions.url and s.filter=? and s.chat_id=subscriptions.chat_id)`)
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(url, filter, newFilter)
+	if err != nil {
+		return err
+	}
+
+	stmt, err = d.db.Prepare("UPDATE 'subscriptions' SET filter=? WHERE url=? and filter=?")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(newFilter, url, filter)
+	return err
+}
+
+// RemoveSubscriptions removes subscriptions of all chats to the filter
+func (d *SQLite) RemoveSubscriptions(url, filter string) error {
+	stmt, err := d.db.Prepare("DELETE FROM 'subscriptions' WHERE url=? and filter=?")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(url, filter)
+	return err
+}
+
+func (d *SQLite) GetNotificationMethods(url, filter string) ([]string, error) {
+	logger := zapwriter.Logger("get_notification_method")
+	logger.Info("",
+		zap.String("url", url),
+		zap.String("filter", filter),
+	)
+	stmt, err := d.db.Prepare("SELECT DISTINCT endpoint FROM 'subscriptions' where url=? and filter=?;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(url, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	var tmp string
+	for rows.Next() {
+		err = rows.Scan(&tmp)
+		if err != nil {
+			logger.Error("error retrieving data",
+				zap.Error(err),
+			)
+			continue
+		}
+		result = append(result, tmp)
+	}
+	_ = rows.Close()
+
+	return result, nil
+}
+
+func (d *SQLite) GetEndpointInfo(endpoint, url, filter string) ([]int64, error) {
+	logger := zapwriter.Logger("get_endpoint_info")
+	logger.Info("",
+		zap.String("endpoint", endpoint),
+		zap.String("url", url),
+		zap.String("filter", filter),
+	)
+	stmt, err := d.db.Prepare("SELECT chat_id FROM 'subscriptions' where endpoint=? and url=? and filter=?;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(endpoint, url, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []int64
+	var tmp int64
+	for rows.Next() {
+		err = rows.Scan(&tmp)
+		if err != nil {
+			logger.Error("error retrieving data",
+				zap.Error(err),
+			)
+			continue
+		}
+		result = append(result, tmp)
+	}
+	_ = rows.Close()
+
+	return result, nil
+}
+
+const subscriptionColumns = "id, endpoint, url, filter, chat_id, channels, silent_channels, notify_description_changes, template, " +
+	"notes_length, notes_mode, muted_until, thread_id"
+
+func scanSubscriptions(logger *zap.Logger, rows *sql.Rows) []*Subscription {
+	var result []*Subscription
+	for rows.Next() {
+		sub := &Subscription{}
+		var channels, silentChannels string
+		var mutedUntil int64
+		err := rows.Scan(&sub.ID, &sub.Endpoint, &sub.Url, &sub.Filter, &sub.ChatID, &channels, &silentChannels, &sub.NotifyDescriptionChanges,
+			&sub.Template, &sub.NotesLength, &sub.NotesMode, &mutedUntil, &sub.ThreadID)
+		if err != nil {
+			logger.Error("error retrieving data",
+				zap.Error(err),
+			)
+			continue
+		}
+		if mutedUntil > 0 {
+			sub.MutedUntil = time.Unix(mutedUntil, 0)
+		}
+		sub.Channels, err = types.ParseChannels(channels)
+		if err != nil {
+			logger.Error("invalid channels stored for subscription",
+				zap.Int64("chat_id", sub.ChatID),
+				zap.String("channels", channels),
+				zap.Error(err),
+			)
+		}
+		sub.SilentChannels, err = types.ParseChannels(silentChannels)
+		if err != nil {
+			logger.Error("invalid silent channels stored for subscription",
+				zap.Int64("chat_id", sub.ChatID),
+				zap.String("silent_channels", silentChannels),
+				zap.Error(err),
+			)
+		}
+		result = append(result, sub)
+	}
+	_ = rows.Close()
+
+	return result
+}
+
+func (d *SQLite) GetSubscriptions(endpoint, url, filter string) ([]*Subscription, error) {
+	logger := zapwriter.Logger("get_subscriptions")
+	stmt, err := d.db.Prepare("SELECT " + subscriptionColumns + " FROM 'subscriptions' where endpoint=? and url=? and filter=?;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(endpoint, url, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSubscriptions(logger, rows), nil
+}
+
+// GetChatSubscriptions returns all subscriptions of the chat, ordered by repo and filter
+func (d *SQLite) GetChatSubscriptions(endpoint string, chatID int64) ([]*Subscription, error) {
+	logger := zapwriter.Logger("get_chat_subscriptions")
+	stmt, err := d.db.Prepare("SELECT " + subscriptionColumns + " FROM 'subscriptions' where endpoint=? and chat_id=? ORDER BY url, filter;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(endpoint, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSubscriptions(logger, rows), nil
+}
+
+func (d *SQLite) GetSubscription(endpoint, url, filter string, chatID int64) (*Subscription, error) {
+	logger := zapwriter.Logger("get_subscription")
+	stmt, err := d.db.Prepare("SELECT " + subscriptionColumns + " FROM 'subscriptions' where endpoint=? and url=? and filter=? and chat_id=?;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(endpoint, url, filter, chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := scanSubscriptions(logger, rows)
+	if len(result) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return result[0], nil
+}
+
+// GetSubscriptionByID returns subscription by its id
+func (d *SQLite) GetSubscriptionByID(id int64) (*Subscription, error) {
+	logger := zapwriter.Logger("get_subscription")
+	stmt, err := d.db.Prepare("SELECT " + subscriptionColumns + " FROM 'subscriptions' where id=?;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := scanSubscriptions(logger, rows)
+	if len(result) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return result[0], nil
+}
+
+// ResetThread moves subscriptions of the chat that were sent to the forum topic to the general topic, used when
+// topic was deleted
+func (d *SQLite) ResetThread(chatID int64, threadID int) error {
+	stmt, err := d.db.Prepare("UPDATE 'subscriptions' SET thread_id=0 WHERE chat_id=? and thread_id=?")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(chatID, threadID)
+	return err
+}
+
+// MuteRepo mutes all subscriptions of the chat to the repo until specified time
+func (d *SQLite) MuteRepo(endpoint, url string, chatID int64, until time.Time) error {
+	stmt, err := d.db.Prepare("UPDATE 'subscriptions' SET muted_until=? WHERE endpoint=? and url=? and chat_id=?")
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(unixTime(until), endpoint, url, chatID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// UpdateSubscriptionSettings stores settings of existing subscription
+func (d *SQLite) UpdateSubscriptionSettings(sub *Subscription) error {
+	stmt, err := d.db.Prepare("UPDATE 'subscriptions' SET channels=?, silent_channels=?, notify_description_changes=?, template=?, notes_length=?, notes_mode=?, muted_until=?, thread_id=? WHERE endpoint=? and url=? and filter=? and chat_id=?")
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(types.JoinChannels(sub.Channels), types.JoinChannels(sub.SilentChannels), sub.NotifyDescriptionChanges,
+		sub.Template, sub.NotesLength, sub.NotesMode, unixTime(sub.MutedUntil), sub.ThreadID, sub.Endpoint, sub.Url, sub.Filter, sub.ChatID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
 }
 
-// GetLastTag - gets Last Tag
-func (d *SQLite) GetLastTag(url, filter string) string {
-	var tag string
-	logger := zapwriter.Logger("get_last_tag")
-	stmt, err := d.db.Prepare("SELECT last_tag from 'last_version' where url=? and filter=?")
+func (d *SQLite) UpdateLastUpdateTime(url, filter, tag string, t time.Time) {
+	logger := zapwriter.Logger("updater")
+	id := -1
+	stmt, err := d.db.Prepare("SELECT id FROM 'last_version' where url=? and filter=?;")
 	if err != nil {
-		logger.Error("error creating statement",
+		logger.Error("error creating statement to get current id",
 			zap.Error(err),
 		)
-		return tag
+		return
 	}
 	rows, err := stmt.Query(url, filter)
 	if err != nil {
 		logger.Error("error retrieving data",
 			zap.Error(err),
 		)
-		return tag
+		return
 	}
+
 	for rows.Next() {
-		err = rows.Scan(&tag)
+		err = rows.Scan(&id)
 		if err != nil {
 			logger.Error("error retrieving data",
 				zap.Error(err),
@@ -231,300 +1398,280 @@ func (d *SQLite) GetLastTag(url, filter string) string {
 		}
 	}
 	_ = rows.Close()
-	return tag
-}
 
-func (d *SQLite) AddFeed(name, repo, filter, messagePattern string) (int, error) {
-	stmt, err := d.db.Prepare("SELECT id FROM 'feeds' where name=? and repo=?;")
-	if err != nil {
-		return -1, err
+	if id != -1 {
+		stmt, err = d.db.Prepare("UPDATE 'last_version' SET date=?, last_tag=? where id=?")
+	} else {
+		stmt, err = d.db.Prepare("INSERT INTO 'last_version' (url, filter, date, last_tag) VALUES (?, ?, ?, ?)")
 	}
-
-	rows, err := stmt.Query(name, repo)
 	if err != nil {
-		return -1, err
-	}
-
-	var id int
-	if rows.Next() {
-		err = rows.Scan(&id)
-		if err != nil {
-			return -1, err
-		}
-		_ = rows.Close()
-		return id, ErrAlreadyExists
+		logger.Error("error creating statement",
+			zap.Error(err),
+		)
+		return
 	}
-	_ = rows.Close()
 
-	stmt, err = d.db.Prepare("INSERT INTO 'feeds' (name, repo, filter, message_pattern) VALUES (?, ?, ?, ?)")
-	if err != nil {
-		return -1, err
+	if id != -1 {
+		_, err = stmt.Exec(t, tag, id)
+	} else {
+		_, err = stmt.Exec(url, filter, t, tag)
 	}
-
-	_, err = stmt.Exec(name, repo, filter, messagePattern)
 	if err != nil {
-		return -1, err
+		logger.Error("error updating data",
+			zap.Error(err),
+		)
+		return
 	}
+}
 
-	stmt, err = d.db.Prepare("SELECT id FROM 'feeds' where name=? and repo=?;")
+func (d *SQLite) GetSeenReleases(url string) ([]*SeenRelease, error) {
+	stmt, err := d.db.Prepare("SELECT release_id, tag, title, link, content_hash, updated, content, status, checked_at FROM 'seen_releases' WHERE url=? ORDER BY updated;")
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
-	rows, err = stmt.Query(name, repo)
+	rows, err := stmt.Query(url)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
 
-	if rows.Next() {
-		err = rows.Scan(&id)
+	var result []*SeenRelease
+	for rows.Next() {
+		r := &SeenRelease{}
+		var checkedAt int64
+		err = rows.Scan(&r.ReleaseID, &r.Tag, &r.Title, &r.Link, &r.ContentHash, &r.Updated, &r.Content, &r.Status, &checkedAt)
 		if err != nil {
-			return -1, err
+			_ = rows.Close()
+			return nil, err
+		}
+		if checkedAt != 0 {
+			r.CheckedAt = time.Unix(checkedAt, 0)
 		}
+		result = append(result, r)
 	}
 	_ = rows.Close()
 
-	return id, nil
+	return result, nil
 }
 
-func (d *SQLite) GetFeed(name string) (*Feed, error) {
-	stmt, err := d.db.Prepare("SELECT name, repo, filter, message_pattern FROM 'feeds' WHERE name=?;")
+// MarkReleaseSeen adds release to the list of seen releases of the feed or updates already known one
+func (d *SQLite) MarkReleaseSeen(url string, release *SeenRelease) error {
+	stmt, err := d.db.Prepare(`INSERT INTO 'seen_releases' (url, release_id, tag, title, link, content_hash, updated, content, status, checked_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (url, release_id) DO UPDATE SET tag=excluded.tag, title=excluded.title, link=excluded.link, content_hash=excluded.content_hash,
+		updated=excluded.updated, content=excluded.content, status=excluded.status, checked_at=excluded.checked_at`)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	rows, err := stmt.Query(name)
+	_, err = stmt.Exec(url, release.ReleaseID, release.Tag, release.Title, release.Link, release.ContentHash, release.Updated, release.Content, release.Status,
+		unixTime(release.CheckedAt))
+	return err
+}
+
+// GetConversation returns state of the conversation user has in the chat, expired conversations are returned too
+func (d *SQLite) GetConversation(chatID, userID int64) (*Conversation, error) {
+	stmt, err := d.db.Prepare("SELECT command, step, data, expires_at FROM 'conversations' WHERE chat_id=? and user_id=?")
 	if err != nil {
 		return nil, err
 	}
 
-	result := &Feed{}
-	for rows.Next() {
-		err = rows.Scan(&result.Name, &result.Repo, &result.Filter, &result.MessagePattern)
-		if err != nil {
-			continue
-		}
+	rows, err := stmt.Query(chatID, userID)
+	if err != nil {
+		return nil, err
 	}
-	_ = rows.Close()
+	defer func() { _ = rows.Close() }()
 
-	return result, nil
-}
+	if !rows.Next() {
+		return nil, ErrNotFound
+	}
 
-func (d *SQLite) ListFeeds() ([]*Feed, error) {
-	rows, err := d.db.Query("SELECT id, name, repo, filter, message_pattern FROM 'feeds';")
+	c := &Conversation{
+		ChatID: chatID,
+		UserID: userID,
+	}
+	var data string
+	var expiresAt int64
+	err = rows.Scan(&c.Command, &c.Step, &data, &expiresAt)
 	if err != nil {
 		return nil, err
 	}
-
-	var result []*Feed
-	var id int
-	var name, repo, filter, pattern string
-	for rows.Next() {
-		err = rows.Scan(&id, &name, &repo, &filter, &pattern)
-		if err != nil {
-			continue
-		}
-
-		f := &Feed{id, repo, filter, name, pattern}
-		result = append(result, f)
+	err = json.Unmarshal([]byte(data), &c.Data)
+	if err != nil {
+		return nil, err
 	}
-	_ = rows.Close()
-
-	return result, nil
+	if c.Data == nil {
+		c.Data = make(map[string]string)
+	}
+	c.ExpiresAt = time.Unix(expiresAt, 0)
+	return c, nil
 }
 
-func (d *SQLite) RemoveFeed(name, repo, filter, messagePattern string) error {
-	logger := zapwriter.Logger("remove_feed")
-	stmt, err := d.db.Prepare("DELETE FROM 'feeds' WHERE name=? and repo=? and filter=? and message_pattern=?")
+// SaveConversation creates or replaces state of the conversation
+func (d *SQLite) SaveConversation(c *Conversation) error {
+	data, err := json.Marshal(c.Data)
 	if err != nil {
-		logger.Error("error creating statement",
-			zap.Error(err),
-		)
 		return err
 	}
 
-	_, err = stmt.Exec(name, repo, filter, messagePattern)
+	stmt, err := d.db.Prepare("INSERT OR REPLACE INTO 'conversations' (chat_id, user_id, command, step, data, expires_at) VALUES (?, ?, ?, ?, ?, ?)")
 	if err != nil {
-		logger.Error("error removing subscription",
-			zap.Error(err),
-		)
+		return err
 	}
 
+	_, err = stmt.Exec(c.ChatID, c.UserID, c.Command, c.Step, string(data), unixTime(c.ExpiresAt))
 	return err
 }
 
-func (d *SQLite) UpdateChatID(oldChatID, newChatID int64) error {
-	stmt, err := d.db.Prepare("UPDATE 'subscriptions' SET chat_id=? WHERE chat_id=?")
+func (d *SQLite) RemoveConversation(chatID, userID int64) error {
+	stmt, err := d.db.Prepare("DELETE FROM 'conversations' WHERE chat_id=? and user_id=?")
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.Exec(newChatID, oldChatID)
+	_, err = stmt.Exec(chatID, userID)
 	return err
 }
 
-func (d *SQLite) AddSubscribtion(endpoint, url, filter string, chatID int64) error {
-	stmt, err := d.db.Prepare("SELECT chat_id FROM 'subscriptions' where endpoint=? and url=? and filter=? and chat_id=?;")
+// GetRoles returns roles granted to users with /admin command
+func (d *SQLite) GetRoles() ([]*Role, error) {
+	stmt, err := d.db.Prepare("SELECT user_id, role, added_by, added_at FROM 'roles' ORDER BY user_id")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rows, err := stmt.Query(endpoint, url, filter, chatID)
+	rows, err := stmt.Query()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer func() { _ = rows.Close() }()
 
-	if rows.Next() {
-		_ = rows.Close()
-		return ErrAlreadyExists
+	var result []*Role
+	for rows.Next() {
+		r := &Role{}
+		var addedAt int64
+		err = rows.Scan(&r.UserID, &r.Role, &r.AddedBy, &addedAt)
+		if err != nil {
+			return nil, err
+		}
+		r.AddedAt = time.Unix(addedAt, 0)
+		result = append(result, r)
 	}
-	_ = rows.Close()
+	return result, nil
+}
 
-	stmt, err = d.db.Prepare("INSERT INTO 'subscriptions' (endpoint, url, filter, chat_id) VALUES (?, ?, ?, ?)")
+// SetRole grants the role to the user, replacing the one user already had
+func (d *SQLite) SetRole(r *Role) error {
+	stmt, err := d.db.Prepare("INSERT OR REPLACE INTO 'roles' (user_id, role, added_by, added_at) VALUES (?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.Exec(endpoint, url, filter, chatID)
-
+	_, err = stmt.Exec(r.UserID, r.Role, r.AddedBy, unixTime(r.AddedAt))
 	return err
 }
 
-func (d *SQLite) RemoveSubscribtion(endpoint, url, filter string, chatID int64) error {
-	stmt, err := d.db.Prepare("DELETE FROM 'subscriptions' WHERE endpoint=? and url=? and filter=? and chat_id=?")
+// RemoveRole revokes role of the user
+func (d *SQLite) RemoveRole(userID int64) error {
+	stmt, err := d.db.Prepare("DELETE FROM 'roles' WHERE user_id=?")
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.Exec(endpoint, url, filter, chatID)
+	res, err := stmt.Exec(userID)
+	if err != nil {
+		return err
+	}
 
-	return err
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
-func (d *SQLite) GetNotificationMethods(url, filter string) ([]string, error) {
-	logger := zapwriter.Logger("get_notification_method")
-	logger.Info("",
-		zap.String("url", url),
-		zap.String("filter", filter),
-	)
-	stmt, err := d.db.Prepare("SELECT DISTINCT endpoint FROM 'subscriptions' where url=? and filter=?;")
+// GetChat returns the chat from the registry of chats the bot was added to
+func (d *SQLite) GetChat(id int64) (*Chat, error) {
+	stmt, err := d.db.Prepare("SELECT id, type, title, username, added_by, added_at, status FROM 'chats' WHERE id=?")
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := stmt.Query(url, filter)
+	rows, err := stmt.Query(id)
 	if err != nil {
 		return nil, err
 	}
-
-	var result []string
-	var tmp string
-	for rows.Next() {
-		err = rows.Scan(&tmp)
-		if err != nil {
-			logger.Error("error retrieving data",
-				zap.Error(err),
-			)
-			continue
-		}
-		result = append(result, tmp)
+	chats, err := scanChats(rows)
+	if err != nil {
+		return nil, err
 	}
-	_ = rows.Close()
-
-	return result, nil
+	if len(chats) == 0 {
+		return nil, ErrNotFound
+	}
+	return chats[0], nil
 }
 
-func (d *SQLite) GetEndpointInfo(endpoint, url, filter string) ([]int64, error) {
-	logger := zapwriter.Logger("get_endpoint_info")
-	logger.Info("",
-		zap.String("endpoint", endpoint),
-		zap.String("url", url),
-		zap.String("filter", filter),
-	)
-	stmt, err := d.db.Prepare("SELECT chat_id FROM 'subscriptions' where endpoint=? and url=? and filter=?;")
+// ListChats returns all chats the bot was ever added to, including the ones it was removed from
+func (d *SQLite) ListChats() ([]*Chat, error) {
+	stmt, err := d.db.Prepare("SELECT id, type, title, username, added_by, added_at, status FROM 'chats' ORDER BY added_at, id")
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := stmt.Query(endpoint, url, filter)
+	rows, err := stmt.Query()
 	if err != nil {
 		return nil, err
 	}
+	return scanChats(rows)
+}
 
-	var result []int64
-	var tmp int64
+func scanChats(rows *sql.Rows) ([]*Chat, error) {
+	defer func() { _ = rows.Close() }()
+
+	var result []*Chat
 	for rows.Next() {
-		err = rows.Scan(&tmp)
+		c := &Chat{}
+		var addedAt int64
+		err := rows.Scan(&c.ID, &c.Type, &c.Title, &c.Username, &c.AddedBy, &addedAt, &c.Status)
 		if err != nil {
-			logger.Error("error retrieving data",
-				zap.Error(err),
-			)
-			continue
+			return nil, err
 		}
-		result = append(result, tmp)
+		c.AddedAt = time.Unix(addedAt, 0)
+		result = append(result, c)
 	}
-	_ = rows.Close()
-
-	return result, nil
+	return result, rows.Err()
 }
 
-func (d *SQLite) UpdateLastUpdateTime(url, filter, tag string, t time.Time) {
-	logger := zapwriter.Logger("updater")
-	id := -1
-	stmt, err := d.db.Prepare("SELECT id FROM 'last_version' where url=? and filter=?;")
-	if err != nil {
-		logger.Error("error creating statement to get current id",
-			zap.Error(err),
-		)
-		return
-	}
-	rows, err := stmt.Query(url, filter)
+// SaveChat adds the chat to the registry or replaces stored one
+func (d *SQLite) SaveChat(c *Chat) error {
+	stmt, err := d.db.Prepare("INSERT OR REPLACE INTO 'chats' (id, type, title, username, added_by, added_at, status) VALUES (?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
-		logger.Error("error retrieving data",
-			zap.Error(err),
-		)
-		return
+		return err
 	}
 
-	for rows.Next() {
-		err = rows.Scan(&id)
-		if err != nil {
-			logger.Error("error retrieving data",
-				zap.Error(err),
-			)
-			break
-		}
-	}
-	_ = rows.Close()
+	_, err = stmt.Exec(c.ID, c.Type, c.Title, c.Username, c.AddedBy, unixTime(c.AddedAt), c.Status)
+	return err
+}
 
-	if id != -1 {
-		stmt, err = d.db.Prepare("UPDATE 'last_version' SET date=?, last_tag=? where id=?")
-	} else {
-		stmt, err = d.db.Prepare("INSERT INTO 'last_version' (url, filter, date, last_tag) VALUES (?, ?, ?, ?)")
-	}
+// RemoveChatSubscriptions removes all subscriptions of the chat and returns how many were removed
+func (d *SQLite) RemoveChatSubscriptions(endpoint string, chatID int64) (int64, error) {
+	stmt, err := d.db.Prepare("DELETE FROM 'subscriptions' WHERE endpoint=? and chat_id=?")
 	if err != nil {
-		logger.Error("error creating statement",
-			zap.Error(err),
-		)
-		return
+		return 0, err
 	}
 
-	if id != -1 {
-		_, err = stmt.Exec(t, tag, id)
-	} else {
-		_, err = stmt.Exec(url, filter, t, tag)
-	}
+	res, err := stmt.Exec(endpoint, chatID)
 	if err != nil {
-		logger.Error("error updating data",
-			zap.Error(err),
-		)
-		return
+		return 0, err
 	}
+	return res.RowsAffected()
 }
 
 func (db *SQLite) AddMessagesToResentQueue(messages []*types.NotificationMessage) error {
 	logger := zapwriter.Logger("add_messages_to_resent_queue")
-	stmt, err := db.db.Prepare("INSERT INTO 'resend_queue' (chat_id, message) VALUES (?, ?)")
+	stmt, err := db.db.Prepare("INSERT INTO 'resend_queue' (chat_id, thread_id, message, fallback, buttons, silent) VALUES (?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		logger.Error("error creating statement",
 			zap.Error(err),
@@ -533,7 +1680,11 @@ func (db *SQLite) AddMessagesToResentQueue(messages []*types.NotificationMessage
 	}
 
 	for _, m := range messages {
-		_, err = stmt.Exec(m.ChatID, m.Message)
+		buttons, err := json.Marshal(m.Buttons)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.Exec(m.ChatID, m.ThreadID, m.Message, m.Fallback, string(buttons), m.Silent)
 		if err != nil {
 			logger.Error("error updating data",
 				zap.Error(err),
@@ -546,7 +1697,7 @@ func (db *SQLite) AddMessagesToResentQueue(messages []*types.NotificationMessage
 
 func (db *SQLite) GetMessagesFromResentQueue() ([]*types.NotificationMessage, error) {
 	logger := zapwriter.Logger("get_messages_from_resent_queue")
-	stmt, err := db.db.Prepare("SELECT chat_id, message FROM 'resend_queue'")
+	stmt, err := db.db.Prepare("SELECT chat_id, thread_id, message, fallback, buttons, silent FROM 'resend_queue'")
 	if err != nil {
 		logger.Error("error creating statement",
 			zap.Error(err),
@@ -563,13 +1714,23 @@ func (db *SQLite) GetMessagesFromResentQueue() ([]*types.NotificationMessage, er
 	results := make([]*types.NotificationMessage, 0)
 	for rows.Next() {
 		res := &types.NotificationMessage{}
-		err = rows.Scan(&res.ChatID, &res.Message)
+		var buttons string
+		err = rows.Scan(&res.ChatID, &res.ThreadID, &res.Message, &res.Fallback, &buttons, &res.Silent)
 		if err != nil {
 			logger.Error("error retrieving data",
 				zap.Error(err),
 			)
 			continue
 		}
+		if buttons != "" {
+			err = json.Unmarshal([]byte(buttons), &res.Buttons)
+			if err != nil {
+				logger.Error("invalid buttons stored for message",
+					zap.String("buttons", buttons),
+					zap.Error(err),
+				)
+			}
+		}
 		results = append(results, res)
 	}
 	_ = rows.Close()
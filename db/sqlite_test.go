@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/types"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/suite"
 )
@@ -60,6 +61,419 @@ func (s *SQLiteSuite) TestUpdateLastTag() {
 	r.Equal(version, versionnew)
 }
 
+func (s *SQLiteSuite) TestSetFeedVersionFilter() {
+	name := "all"
+	repo := "lomik/go-carbon"
+	versionFilter := `constraint=">=0.15" prereleases=exclude`
+
+	r := s.Require()
+	_, err := s.db.AddFeed(name, repo, "^v", "")
+	r.NoError(err)
+
+	err = s.db.SetFeedVersionFilter(name, repo, versionFilter)
+	r.NoError(err)
+
+	feed, err := s.db.GetFeed(name)
+	r.NoError(err)
+	r.Equal(versionFilter, feed.VersionFilter)
+
+	err = s.db.SetFeedVersionFilter("unknown", repo, versionFilter)
+	r.ErrorIs(err, ErrNotFound)
+}
+
+func (s *SQLiteSuite) TestSetFeedMessagePattern() {
+	name := "pattern"
+	repo := "lomik/go-carbon"
+	pattern := "{{.Repo}} {{.Tag}}: {{.Link}}"
+
+	r := s.Require()
+	_, err := s.db.AddFeed(name, repo, "^v", "")
+	r.NoError(err)
+
+	err = s.db.SetFeedMessagePattern(name, repo, pattern)
+	r.NoError(err)
+
+	feed, err := s.db.GetFeed(name)
+	r.NoError(err)
+	r.Equal(pattern, feed.MessagePattern)
+
+	err = s.db.SetFeedMessagePattern("unknown", repo, pattern)
+	r.ErrorIs(err, ErrNotFound)
+}
+
+func (s *SQLiteSuite) TestSetFeedFilter() {
+	name := "regexp"
+	repo := "lomik/go-carbon"
+	url := "https://github.com/lomik/go-carbon/releases.atom"
+	updated := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	r := s.Require()
+	_, err := s.db.AddFeed(name, repo, "^v", "")
+	r.NoError(err)
+	s.db.UpdateLastUpdateTime(url, "^v", "v1.2.0", updated)
+
+	err = s.db.SetFeedFilter(name, repo, url, "^v2\\.")
+	r.NoError(err)
+
+	feed, err := s.db.GetFeed(name)
+	r.NoError(err)
+	r.Equal("^v2\\.", feed.Filter)
+
+	// Last announced version follows the regexp
+	r.Equal("v1.2.0", s.db.GetLastTag(url, "^v2\\."))
+	r.True(updated.Equal(s.db.GetLastUpdateTime(url, "^v2\\.")))
+	r.Equal("", s.db.GetLastTag(url, "^v"))
+
+	// It's copied if another filter still uses the old regexp
+	_, err = s.db.AddFeed("shared", repo, "^v2\\.", "")
+	r.NoError(err)
+	err = s.db.SetFeedFilter(name, repo, url, "^v2\\.0")
+	r.NoError(err)
+	r.Equal("v1.2.0", s.db.GetLastTag(url, "^v2\\."))
+	r.Equal("v1.2.0", s.db.GetLastTag(url, "^v2\\.0"))
+
+	err = s.db.SetFeedFilter("unknown", repo, url, "^v")
+	r.ErrorIs(err, ErrNotFound)
+}
+
+func (s *SQLiteSuite) TestSetFilterRules() {
+	repo := "lomik/go-carbon"
+	name := "rules"
+	rules := []*FilterRule{
+		{Action: RuleActionExclude, Field: RuleFieldTitle, Pattern: "-rc"},
+		{Action: RuleActionExclude, Field: RuleFieldTitle, Pattern: "-nightly"},
+		{Action: RuleActionInclude, Field: RuleFieldBody, Pattern: "(?i)security"},
+	}
+
+	r := s.Require()
+	err := s.db.SetFilterRules(repo, name, rules)
+	r.NoError(err)
+
+	stored, err := s.db.ListFilterRules(repo, name)
+	r.NoError(err)
+	r.Equal(rules, stored)
+
+	err = s.db.SetFilterRules(repo, name, rules[1:])
+	r.NoError(err)
+
+	stored, err = s.db.ListFilterRules(repo, name)
+	r.NoError(err)
+	r.Equal(rules[1:], stored)
+}
+
+func (s *SQLiteSuite) TestSubscriptionSettings() {
+	endpoint := "telegram"
+	url := "lomik/go-carbon"
+	filter := "channels"
+	chatID := int64(42)
+
+	r := s.Require()
+	err := s.db.AddSubscribtion(endpoint, url, filter, chatID, 0)
+	r.NoError(err)
+
+	subs, err := s.db.GetSubscriptions(endpoint, url, filter)
+	r.NoError(err)
+	r.Len(subs, 1)
+	r.Empty(subs[0].Channels)
+	r.True(subs[0].NotifyDescriptionChanges)
+
+	deliver, silent := subs[0].Accepts([]types.Channel{types.ChannelNightly})
+	r.True(deliver)
+	r.False(silent)
+
+	channels := []types.Channel{types.ChannelStable, types.ChannelPrerelease}
+	silentChannels := []types.Channel{types.ChannelPrerelease}
+	subs[0].Channels = channels
+	subs[0].SilentChannels = silentChannels
+	subs[0].NotifyDescriptionChanges = false
+	subs[0].Template = "{{.Repo}} {{.Tag}}"
+	subs[0].NotesLength = 1000
+	subs[0].NotesMode = "document"
+	err = s.db.UpdateSubscriptionSettings(subs[0])
+	r.NoError(err)
+
+	sub, err := s.db.GetSubscription(endpoint, url, filter, chatID)
+	r.NoError(err)
+	r.Equal(channels, sub.Channels)
+	r.Equal(silentChannels, sub.SilentChannels)
+	r.False(sub.NotifyDescriptionChanges)
+	r.Equal("{{.Repo}} {{.Tag}}", sub.Template)
+	r.Equal(1000, sub.NotesLength)
+	r.Equal("document", sub.NotesMode)
+	subs = []*Subscription{sub}
+
+	deliver, _ = subs[0].Accepts([]types.Channel{types.ChannelNightly})
+	r.False(deliver)
+	deliver, silent = subs[0].Accepts([]types.Channel{types.ChannelPrerelease})
+	r.True(deliver)
+	r.True(silent)
+	deliver, silent = subs[0].Accepts([]types.Channel{types.ChannelLTS, types.ChannelStable})
+	r.True(deliver)
+	r.False(silent)
+
+	_, err = s.db.GetSubscription(endpoint, url, filter, chatID+1)
+	r.ErrorIs(err, ErrNotFound)
+
+	byID, err := s.db.GetSubscriptionByID(sub.ID)
+	r.NoError(err)
+	r.Equal(sub, byID)
+
+	until := time.Now().Add(7 * 24 * time.Hour).Truncate(time.Second)
+	err = s.db.MuteRepo(endpoint, url, chatID, until)
+	r.NoError(err)
+	sub, err = s.db.GetSubscription(endpoint, url, filter, chatID)
+	r.NoError(err)
+	r.True(until.Equal(sub.MutedUntil))
+
+	err = s.db.MuteRepo(endpoint, url, chatID+1, until)
+	r.ErrorIs(err, ErrNotFound)
+
+	sub.ChatID = chatID + 1
+	err = s.db.UpdateSubscriptionSettings(sub)
+	r.ErrorIs(err, ErrNotFound)
+}
+
+func (s *SQLiteSuite) TestSubscriptionThread() {
+	endpoint := "telegram"
+	url := "lomik/go-carbon"
+	chatID := int64(-100500)
+
+	r := s.Require()
+	r.NoError(s.db.AddSubscribtion(endpoint, url, "stable", chatID, 7))
+	r.NoError(s.db.AddSubscribtion(endpoint, url, "all", chatID, 7))
+	r.NoError(s.db.AddSubscribtion(endpoint, url, "nightly", chatID, 8))
+
+	sub, err := s.db.GetSubscription(endpoint, url, "stable", chatID)
+	r.NoError(err)
+	r.Equal(7, sub.ThreadID)
+
+	sub.ThreadID = 9
+	r.NoError(s.db.UpdateSubscriptionSettings(sub))
+	sub, err = s.db.GetSubscription(endpoint, url, "stable", chatID)
+	r.NoError(err)
+	r.Equal(9, sub.ThreadID)
+
+	r.NoError(s.db.ResetThread(chatID, 8))
+	subs, err := s.db.GetChatSubscriptions(endpoint, chatID)
+	r.NoError(err)
+	threads := make(map[string]int)
+	for _, sub := range subs {
+		threads[sub.Filter] = sub.ThreadID
+	}
+	r.Equal(map[string]int{"stable": 9, "all": 7, "nightly": 0}, threads)
+}
+
+func (s *SQLiteSuite) TestMarkReleaseSeen() {
+	url := "https://github.com/lomik/go-carbon/releases.atom"
+	release := &SeenRelease{
+		ReleaseID:   "tag:github.com,2008:Repository/1/v0.1.0",
+		Tag:         "v0.1.0",
+		Title:       "v0.1.0",
+		Link:        "https://github.com/lomik/go-carbon/releases/tag/v0.1.0",
+		ContentHash: "hash1",
+		Updated:     time.Date(2018, time.June, 12, 7, 8, 0, 0, time.UTC),
+	}
+
+	r := s.Require()
+	err := s.db.MarkReleaseSeen(url, release)
+	r.NoError(err)
+
+	edited := *release
+	edited.ContentHash = "hash2"
+	edited.Content = "<p>fixed typo</p>"
+	err = s.db.MarkReleaseSeen(url, &edited)
+	r.NoError(err)
+
+	seen, err := s.db.GetSeenReleases(url)
+	r.NoError(err)
+	r.Len(seen, 1)
+	r.Equal(&edited, seen[0])
+
+	checked := edited
+	checked.CheckedAt = time.Date(2018, time.June, 13, 0, 0, 0, 0, time.UTC)
+	err = s.db.MarkReleaseSeen(url, &checked)
+	r.NoError(err)
+	seen, err = s.db.GetSeenReleases(url)
+	r.NoError(err)
+	r.True(checked.CheckedAt.Equal(seen[0].CheckedAt))
+}
+
+func (s *SQLiteSuite) TestGetChatSubscriptions() {
+	endpoint := "telegram"
+	chatID := int64(-1001)
+
+	r := s.Require()
+	subs, err := s.db.GetChatSubscriptions(endpoint, chatID)
+	r.NoError(err)
+	r.Empty(subs)
+
+	r.NoError(s.db.AddSubscribtion(endpoint, "lomik/go-carbon", "stable", chatID, 0))
+	r.NoError(s.db.AddSubscribtion(endpoint, "go-graphite/carbonapi", "all", chatID, 0))
+	r.NoError(s.db.AddSubscribtion(endpoint, "lomik/go-carbon", "all", chatID, 0))
+	r.NoError(s.db.AddSubscribtion(endpoint, "lomik/go-carbon", "all", chatID-1, 0))
+
+	subs, err = s.db.GetChatSubscriptions(endpoint, chatID)
+	r.NoError(err)
+	r.Len(subs, 3)
+	r.Equal("go-graphite/carbonapi", subs[0].Url)
+	r.Equal("all", subs[1].Filter)
+	r.Equal("stable", subs[2].Filter)
+	for _, sub := range subs {
+		r.Equal(chatID, sub.ChatID)
+	}
+}
+
+func (s *SQLiteSuite) TestMoveSubscriptions() {
+	endpoint := "telegram"
+	url := "go-graphite/go-carbon"
+
+	r := s.Require()
+	r.NoError(s.db.AddSubscribtion(endpoint, url, "old", 1, 0))
+	r.NoError(s.db.AddSubscribtion(endpoint, url, "old", 2, 0))
+	r.NoError(s.db.AddSubscribtion(endpoint, url, "new", 2, 0))
+	r.NoError(s.db.AddSubscribtion(endpoint, url, "other", 3, 0))
+
+	err := s.db.MoveSubscriptions(url, "old", "new")
+	r.NoError(err)
+
+	subs, err := s.db.GetSubscriptions(endpoint, url, "old")
+	r.NoError(err)
+	r.Empty(subs)
+	subs, err = s.db.GetSubscriptions(endpoint, url, "new")
+	r.NoError(err)
+	r.Len(subs, 2)
+
+	err = s.db.RemoveSubscriptions(url, "new")
+	r.NoError(err)
+	subs, err = s.db.GetSubscriptions(endpoint, url, "new")
+	r.NoError(err)
+	r.Empty(subs)
+	subs, err = s.db.GetSubscriptions(endpoint, url, "other")
+	r.NoError(err)
+	r.Len(subs, 1)
+}
+
+func (s *SQLiteSuite) TestConversation() {
+	var chatID int64 = -100500
+	var userID int64 = 42
+
+	r := s.Require()
+	_, err := s.db.GetConversation(chatID, userID)
+	r.ErrorIs(err, ErrNotFound)
+
+	c := &Conversation{
+		ChatID:    chatID,
+		UserID:    userID,
+		Command:   "/new",
+		Step:      "repo",
+		Data:      map[string]string{"source": "releases"},
+		ExpiresAt: time.Now().Add(time.Minute).Truncate(time.Second),
+	}
+	err = s.db.SaveConversation(c)
+	r.NoError(err)
+
+	c.Step = "preset"
+	c.Data["repo"] = "lomik/go-carbon"
+	err = s.db.SaveConversation(c)
+	r.NoError(err)
+
+	saved, err := s.db.GetConversation(chatID, userID)
+	r.NoError(err)
+	r.Equal(c.Step, saved.Step)
+	r.Equal(c.Data, saved.Data)
+	r.True(c.ExpiresAt.Equal(saved.ExpiresAt))
+
+	_, err = s.db.GetConversation(chatID, userID+1)
+	r.ErrorIs(err, ErrNotFound)
+
+	err = s.db.RemoveConversation(chatID, userID)
+	r.NoError(err)
+	_, err = s.db.GetConversation(chatID, userID)
+	r.ErrorIs(err, ErrNotFound)
+}
+
+func (s *SQLiteSuite) TestRoles() {
+	r := s.Require()
+	addedAt := time.Now().Truncate(time.Second)
+	r.NoError(s.db.SetRole(&Role{UserID: 2, Role: "feed_manager", AddedBy: 1, AddedAt: addedAt}))
+	r.NoError(s.db.SetRole(&Role{UserID: 3, Role: "feed_manager", AddedBy: 1, AddedAt: addedAt}))
+	r.NoError(s.db.SetRole(&Role{UserID: 3, Role: "admin", AddedBy: 2, AddedAt: addedAt}))
+
+	roles, err := s.db.GetRoles()
+	r.NoError(err)
+	r.Len(roles, 2)
+	r.Equal(int64(2), roles[0].UserID)
+	r.Equal("feed_manager", roles[0].Role)
+	r.Equal("admin", roles[1].Role)
+	r.Equal(int64(2), roles[1].AddedBy)
+	r.True(addedAt.Equal(roles[1].AddedAt))
+
+	r.NoError(s.db.RemoveRole(2))
+	r.ErrorIs(s.db.RemoveRole(2), ErrNotFound)
+	roles, err = s.db.GetRoles()
+	r.NoError(err)
+	r.Len(roles, 1)
+}
+
+func (s *SQLiteSuite) TestChats() {
+	r := s.Require()
+	addedAt := time.Now().Truncate(time.Second)
+
+	_, err := s.db.GetChat(-100)
+	r.ErrorIs(err, ErrNotFound)
+
+	r.NoError(s.db.SaveChat(&Chat{ID: -100, Type: "supergroup", Title: "Releases", AddedBy: 1, AddedAt: addedAt, Status: "member"}))
+	r.NoError(s.db.SaveChat(&Chat{ID: -200, Type: "channel", Username: "releases", AddedBy: 1, AddedAt: addedAt.Add(time.Second), Status: "administrator"}))
+	r.NoError(s.db.SaveChat(&Chat{ID: -100, Type: "supergroup", Title: "Releases", AddedBy: 1, AddedAt: addedAt, Status: "kicked"}))
+
+	chat, err := s.db.GetChat(-100)
+	r.NoError(err)
+	r.Equal("kicked", chat.Status)
+	r.Equal(int64(1), chat.AddedBy)
+	r.True(addedAt.Equal(chat.AddedAt))
+
+	chats, err := s.db.ListChats()
+	r.NoError(err)
+	r.Len(chats, 2)
+	r.Equal(int64(-100), chats[0].ID)
+	r.Equal("releases", chats[1].Username)
+
+	r.NoError(s.db.AddSubscribtion("telegram", "lomik/go-carbon", "all", -100, 0))
+	r.NoError(s.db.AddSubscribtion("telegram", "lomik/go-carbon", "stable", -100, 0))
+	r.NoError(s.db.AddSubscribtion("telegram", "lomik/go-carbon", "all", -200, 0))
+	removed, err := s.db.RemoveChatSubscriptions("telegram", -100)
+	r.NoError(err)
+	r.Equal(int64(2), removed)
+	subs, err := s.db.GetSubscriptions("telegram", "lomik/go-carbon", "all")
+	r.NoError(err)
+	r.Len(subs, 1)
+
+	// Chats subscribed before the registry existed are added by the migration
+	r.NoError(s.db.AddSubscribtion("telegram", "lomik/go-carbon", "all", 300, 0))
+	r.NoError(s.db.AddSubscribtion("telegram", "lomik/go-carbon", "stable", 300, 0))
+	r.NoError(s.db.AddSubscribtion("telegram", "lomik/go-carbon", "stable", -300, 0))
+	_, err = s.db.(*SQLite).db.Exec(seedChatsQuery)
+	r.NoError(err)
+	chat, err = s.db.GetChat(300)
+	r.NoError(err)
+	r.Equal("private", chat.Type)
+	r.Equal("member", chat.Status)
+	chat, err = s.db.GetChat(-300)
+	r.NoError(err)
+	r.Equal("unknown", chat.Type)
+	chat, err = s.db.GetChat(-200)
+	r.NoError(err)
+	r.Equal("administrator", chat.Status)
+
+	// Chat that became supergroup keeps its registry record
+	r.NoError(s.db.UpdateChatID(-300, -1000300))
+	_, err = s.db.GetChat(-300)
+	r.ErrorIs(err, ErrNotFound)
+	chat, err = s.db.GetChat(-1000300)
+	r.NoError(err)
+	r.Equal("unknown", chat.Type)
+}
+
 func TestDBSuite(t *testing.T) {
 	ts := &SQLiteSuite{}
 	suite.Run(t, ts)
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/feeds"
+	"github.com/Civil/github2telegram/render"
+)
+
+// dryRun prints which of the recent releases of the repo would be announced by the filter and the preview of the
+// latest announcement, the same way /test command does
+func dryRun(w io.Writer, repo, filterRegexp, messagePattern string) error {
+	re, err := regexp.Compile(filterRegexp)
+	if err != nil {
+		return fmt.Errorf("invalid regexp: %w", err)
+	}
+	filter := &configs.FiltersConfig{
+		Name:           "test",
+		Filter:         filterRegexp,
+		FilterRegex:    re,
+		MessagePattern: messagePattern,
+	}
+
+	results, err := feeds.DryRun(repo, filter)
+	if err != nil {
+		return fmt.Errorf("failed to fetch releases of %s: %w", repo, err)
+	}
+
+	_, _ = fmt.Fprintf(w, "Recent releases of %s tested against %s, oldest first:\n", repo, filterRegexp)
+	matched := 0
+	for _, r := range results {
+		if r.Update != nil {
+			matched++
+			_, _ = fmt.Fprintf(w, "  + %s\n", r.Tag)
+		} else {
+			_, _ = fmt.Fprintf(w, "  - %s: %s\n", r.Tag, r.Reason)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "\n%v of %v releases matched\n", matched, len(results))
+
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i].Update == nil {
+			continue
+		}
+		message, err := render.Render(results[i].Update, messagePattern, render.FormatPlain)
+		if err != nil {
+			return fmt.Errorf("failed to render template: %w", err)
+		}
+		_, _ = fmt.Fprintf(w, "\nLatest one would be announced as:\n%s\n", message)
+		break
+	}
+	return nil
+}
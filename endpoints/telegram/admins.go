@@ -0,0 +1,135 @@
+package telegram
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"go.uber.org/zap"
+)
+
+// adminCacheTTL is how long admins of a chat are trusted without refreshing. Updates about chat members keep the
+// cache current, but they are delivered only to chats where the bot is an admin.
+const adminCacheTTL = time.Hour
+
+// allowedUpdates are update types the bot handles, chat_member updates are not sent unless requested explicitly
+var allowedUpdates = []string{
+	telego.MessageUpdates,
+	telego.ChannelPostUpdates,
+	telego.CallbackQueryUpdates,
+	telego.InlineQueryUpdates,
+	telego.MyChatMemberUpdates,
+	telego.ChatMemberUpdates,
+}
+
+type chatAdmins struct {
+	ids     map[int64]bool
+	fetched time.Time
+}
+
+// adminCache keeps admins of the chats, it's used by update handlers and callbacks concurrently
+type adminCache struct {
+	sync.Mutex
+	ttl   time.Duration
+	chats map[int64]*chatAdmins
+}
+
+func newAdminCache(ttl time.Duration) *adminCache {
+	return &adminCache{
+		ttl:   ttl,
+		chats: make(map[int64]*chatAdmins),
+	}
+}
+
+// get returns admins of the chat and false if they are unknown or expired
+func (c *adminCache) get(chatID int64, now time.Time) (map[int64]bool, bool) {
+	c.Lock()
+	defer c.Unlock()
+	admins, ok := c.chats[chatID]
+	if !ok {
+		return nil, false
+	}
+	return admins.ids, now.Sub(admins.fetched) < c.ttl
+}
+
+func (c *adminCache) set(chatID int64, ids []int64, now time.Time) {
+	admins := &chatAdmins{
+		ids:     make(map[int64]bool, len(ids)),
+		fetched: now,
+	}
+	for _, id := range ids {
+		admins.ids[id] = true
+	}
+
+	c.Lock()
+	c.chats[chatID] = admins
+	c.Unlock()
+}
+
+// update applies promotion or demotion of the user, chats that are not cached yet are fetched on first use
+func (c *adminCache) update(chatID, userID int64, admin bool) {
+	c.Lock()
+	defer c.Unlock()
+	admins, ok := c.chats[chatID]
+	if !ok {
+		return
+	}
+	// Map is copied, as previous one might be in use by get's caller
+	ids := make(map[int64]bool, len(admins.ids)+1)
+	for id := range admins.ids {
+		if id != userID {
+			ids[id] = true
+		}
+	}
+	if admin {
+		ids[userID] = true
+	}
+	c.chats[chatID] = &chatAdmins{ids: ids, fetched: admins.fetched}
+}
+
+func (c *adminCache) forget(chatID int64) {
+	c.Lock()
+	delete(c.chats, chatID)
+	c.Unlock()
+}
+
+// chatAdminIDs returns ids of the chat's admins, using the cache while it's fresh. Stale list is used if admins can't
+// be fetched.
+func (e *TelegramEndpoint) chatAdminIDs(logger *zap.Logger, chatID int64) (map[int64]bool, bool) {
+	now := time.Now()
+	cached, fresh := e.admins.get(chatID, now)
+	if fresh {
+		return cached, true
+	}
+
+	params := &telego.GetChatAdministratorsParams{}
+	members, err := e.api.GetChatAdministrators(params.WithChatID(tu.ID(chatID)))
+	if err != nil {
+		logger.Error("failed to get chat admins",
+			zap.Int64("chat_id", chatID),
+			zap.Bool("stale_cache", cached != nil),
+			zap.Error(err),
+		)
+		return cached, cached != nil
+	}
+
+	ids := make([]int64, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.MemberUser().ID)
+	}
+	e.admins.set(chatID, ids, now)
+	admins, _ := e.admins.get(chatID, now)
+	return admins, true
+}
+
+// handleChatMember keeps admins cache current when users are promoted or demoted
+func (e *TelegramEndpoint) handleChatMember(logger *zap.Logger, updated *telego.ChatMemberUpdated) {
+	member := updated.NewChatMember.MemberUser()
+	logger.Debug("chat member updated",
+		zap.Int64("chat_id", updated.Chat.ID),
+		zap.Int64("user_id", member.ID),
+		zap.String("status", updated.NewChatMember.MemberStatus()),
+	)
+	e.admins.update(updated.Chat.ID, member.ID, isAdminMember(updated.NewChatMember))
+}
@@ -0,0 +1,42 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminCache(t *testing.T) {
+	now := time.Now()
+	c := newAdminCache(time.Hour)
+
+	_, ok := c.get(-1, now)
+	require.False(t, ok)
+
+	// Chats that are not cached yet are not populated by updates
+	c.update(-1, 1, true)
+	admins, _ := c.get(-1, now)
+	require.Nil(t, admins)
+
+	c.set(-1, []int64{1, 2}, now)
+	admins, ok = c.get(-1, now.Add(time.Minute))
+	require.True(t, ok)
+	require.Equal(t, map[int64]bool{1: true, 2: true}, admins)
+
+	c.update(-1, 3, true)
+	c.update(-1, 1, false)
+	admins, ok = c.get(-1, now)
+	require.True(t, ok)
+	require.Equal(t, map[int64]bool{2: true, 3: true}, admins)
+
+	// Expired list is still returned to be used if admins can't be fetched
+	admins, ok = c.get(-1, now.Add(2*time.Hour))
+	require.False(t, ok)
+	require.Equal(t, map[int64]bool{2: true, 3: true}, admins)
+
+	c.forget(-1)
+	admins, ok = c.get(-1, now)
+	require.False(t, ok)
+	require.Nil(t, admins)
+}
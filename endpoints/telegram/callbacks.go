@@ -0,0 +1,201 @@
+package telegram
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/types"
+)
+
+const (
+	callbackMute        = "mute"
+	callbackUnsubscribe = "unsub"
+
+	muteDuration = 7 * 24 * time.Hour
+)
+
+// callbackHandler handles button press, arg is the part of callback data after the prefix. Returned text is shown to
+// the user who pressed the button.
+type callbackHandler func(arg string, query *telego.CallbackQuery) (string, error)
+
+// callbackData returns data of the button that is handled by callback with the given prefix
+func callbackData(prefix string, arg string) string {
+	return prefix + ":" + arg
+}
+
+// notificationButtons returns inline keyboard that is attached to the notification
+func notificationButtons(update *types.Update, sub *db.Subscription) [][]types.Button {
+	var links []types.Button
+	if update.Link != "" && update.Type != types.Deleted {
+		links = append(links, types.Button{Text: "Open release", URL: update.Link})
+	}
+	if update.PreviousTag != "" && update.Tag != "" && update.Type != types.Deleted && update.Type != types.ReleasesSummary {
+		links = append(links, types.Button{
+			Text: "Compare",
+			URL:  "https://github.com/" + update.Repo + "/compare/" + update.PreviousTag + "..." + update.Tag,
+		})
+	}
+
+	var buttons [][]types.Button
+	if len(links) > 0 {
+		buttons = append(buttons, links)
+	}
+	if sub.ID != 0 {
+		id := strconv.FormatInt(sub.ID, 10)
+		buttons = append(buttons, []types.Button{
+			{Text: "Mute this repo for 7 days", Data: callbackData(callbackMute, id)},
+			{Text: "Unsubscribe", Data: callbackData(callbackUnsubscribe, id)},
+		})
+	}
+	return buttons
+}
+
+func inlineKeyboard(buttons [][]types.Button) *telego.InlineKeyboardMarkup {
+	rows := make([][]telego.InlineKeyboardButton, 0, len(buttons))
+	for _, row := range buttons {
+		r := make([]telego.InlineKeyboardButton, 0, len(row))
+		for _, b := range row {
+			button := tu.InlineKeyboardButton(b.Text)
+			if b.URL != "" {
+				button = button.WithURL(b.URL)
+			} else {
+				button = button.WithCallbackData(b.Data)
+			}
+			r = append(r, button)
+		}
+		rows = append(rows, r)
+	}
+	return tu.InlineKeyboard(rows...)
+}
+
+// linkButtons returns keyboard without buttons that are handled by the bot, nil if nothing is left
+func linkButtons(keyboard *telego.InlineKeyboardMarkup) *telego.InlineKeyboardMarkup {
+	if keyboard == nil {
+		return nil
+	}
+	var rows [][]telego.InlineKeyboardButton
+	for _, row := range keyboard.InlineKeyboard {
+		var r []telego.InlineKeyboardButton
+		for _, b := range row {
+			if b.URL != "" {
+				r = append(r, b)
+			}
+		}
+		if len(r) > 0 {
+			rows = append(rows, r)
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tu.InlineKeyboard(rows...)
+}
+
+// handleCallback dispatches button press to its handler and answers the query
+func (e *TelegramEndpoint) handleCallback(logger *zap.Logger, query *telego.CallbackQuery) {
+	logger.Debug("got callback query",
+		zap.String("from", query.From.Username),
+		zap.String("data", query.Data),
+	)
+
+	var text string
+	var err error
+	prefix, arg, _ := strings.Cut(query.Data, ":")
+	f, ok := e.callbacks[prefix]
+	if ok {
+		text, err = f(arg, query)
+	} else {
+		err = errors.New("unknown button")
+	}
+
+	answer := tu.CallbackQuery(query.ID).WithText(text)
+	if err != nil {
+		answer = answer.WithText(err.Error()).WithShowAlert()
+	}
+	err = e.api.AnswerCallbackQuery(answer)
+	if err != nil {
+		logger.Error("failed to answer callback query",
+			zap.String("from", query.From.Username),
+			zap.String("data", query.Data),
+			zap.Error(err),
+		)
+	}
+}
+
+// callbackSubscription returns subscription the button belongs to, if user is allowed to change it
+func (e *TelegramEndpoint) callbackSubscription(arg string, query *telego.CallbackQuery) (*db.Subscription, error) {
+	if query.Message == nil {
+		return nil, errors.New("message is too old")
+	}
+	chat := query.Message.GetChat()
+	if !e.hasRole(chat, &query.From, roleChatAdmin) {
+		return nil, errUnauthorized
+	}
+
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid button")
+	}
+	sub, err := e.db.GetSubscriptionByID(id)
+	if err != nil || sub.ChatID != chat.ID {
+		return nil, errors.New("subscription not found, probably you have already unsubscribed")
+	}
+	return sub, nil
+}
+
+func (e *TelegramEndpoint) callbackMute(arg string, query *telego.CallbackQuery) (string, error) {
+	logger := e.logger.With(zap.String("handler", "callbackMute"))
+	sub, err := e.callbackSubscription(arg, query)
+	if err != nil {
+		return "", err
+	}
+
+	until := time.Now().Add(muteDuration)
+	err = e.db.MuteRepo(TelegramEndpointName, sub.Url, sub.ChatID, until)
+	if err != nil {
+		logger.Error("failed to mute repo",
+			zap.String("url", sub.Url),
+			zap.Int64("chat_id", sub.ChatID),
+			zap.Error(err),
+		)
+		return "", errors.New("error occurred while trying to mute repo")
+	}
+	return sub.Url + " is muted until " + until.Format(time.DateOnly), nil
+}
+
+func (e *TelegramEndpoint) callbackUnsubscribe(arg string, query *telego.CallbackQuery) (string, error) {
+	logger := e.logger.With(zap.String("handler", "callbackUnsubscribe"))
+	sub, err := e.callbackSubscription(arg, query)
+	if err != nil {
+		return "", err
+	}
+
+	err = e.unsubscribe(logger, sub.ChatID, sub.Url, sub.Filter)
+	if err != nil {
+		return "", err
+	}
+
+	// Only link buttons of the notification are still useful
+	params := &telego.EditMessageReplyMarkupParams{
+		ChatID:    tu.ID(sub.ChatID),
+		MessageID: query.Message.GetMessageID(),
+	}
+	if msg, ok := query.Message.(*telego.Message); ok {
+		params.ReplyMarkup = linkButtons(msg.ReplyMarkup)
+	}
+	_, err = e.api.EditMessageReplyMarkup(params)
+	if err != nil {
+		logger.Warn("failed to remove buttons",
+			zap.Int64("chat_id", sub.ChatID),
+			zap.Error(err),
+		)
+	}
+	return "successfully unsubscribed", nil
+}
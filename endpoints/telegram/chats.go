@@ -0,0 +1,144 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/render"
+)
+
+const onboardingMessage = `Hi\! I announce new releases of repositories\.
+
+Admins of this chat can subscribe it with ` + "`/subscribe owner/repo filter`" + `, use /list to see available feeds and /help for all commands\.`
+
+// isMemberStatus returns true if bot with the status is still in the chat
+func isMemberStatus(status string) bool {
+	return status != telego.MemberStatusLeft && status != telego.MemberStatusBanned
+}
+
+// handleMyChatMember records the bot being added to or removed from the chat. New groups and channels get a short
+// introduction, subscriptions of chats the bot was removed from are deleted, as they can't be delivered anymore.
+func (e *TelegramEndpoint) handleMyChatMember(logger *zap.Logger, updated *telego.ChatMemberUpdated) {
+	chatID := updated.Chat.ID
+	status := updated.NewChatMember.MemberStatus()
+	logger = logger.With(
+		zap.Int64("chat_id", chatID),
+		zap.String("chat_type", updated.Chat.Type),
+		zap.String("status", status),
+		zap.Int64("from", updated.From.ID),
+	)
+
+	// Bot might have missed changes of admins while it wasn't an admin itself
+	e.admins.forget(chatID)
+
+	stored, err := e.db.GetChat(chatID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		logger.Error("failed to get chat", zap.Error(err))
+		return
+	}
+	wasMember := stored != nil && isMemberStatus(stored.Status)
+	isMember := isMemberStatus(status)
+
+	chat := &db.Chat{
+		ID:       chatID,
+		Type:     updated.Chat.Type,
+		Title:    updated.Chat.Title,
+		Username: updated.Chat.Username,
+		AddedBy:  updated.From.ID,
+		AddedAt:  time.Unix(updated.Date, 0),
+		Status:   status,
+	}
+	if stored != nil && (wasMember || !isMember) {
+		// Only rejoining resets who added the bot
+		chat.AddedBy = stored.AddedBy
+		chat.AddedAt = stored.AddedAt
+	}
+	err = e.db.SaveChat(chat)
+	if err != nil {
+		logger.Error("failed to save chat", zap.Error(err))
+	}
+
+	switch {
+	case isMember && !wasMember:
+		logger.Info("bot was added to chat")
+		if updated.Chat.Type == telego.ChatTypePrivate {
+			return
+		}
+		err = e.sendThreadMessage(chatID, 0, onboardingMessage)
+		if err != nil {
+			logger.Warn("failed to send onboarding message", zap.Error(err))
+		}
+	case !isMember:
+		removed, err := e.db.RemoveChatSubscriptions(TelegramEndpointName, chatID)
+		if err != nil {
+			logger.Error("failed to remove subscriptions of the chat", zap.Error(err))
+			return
+		}
+		logger.Info("bot was removed from chat",
+			zap.Int64("removed_subscriptions", removed),
+		)
+	}
+}
+
+func (e *TelegramEndpoint) handlerChats(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "chats"))
+	chats, err := e.db.ListChats()
+	if err != nil {
+		logger.Error("error getting chats", zap.Error(err))
+		return errors.New("error occurred while trying to get chats")
+	}
+
+	all := args.get("filter") == "all"
+	lines := make([]string, 0, len(chats))
+	for _, c := range chats {
+		if !all && !isMemberStatus(c.Status) {
+			continue
+		}
+		subs, err := e.db.GetChatSubscriptions(TelegramEndpointName, c.ID)
+		if err != nil {
+			logger.Error("error getting chat subscriptions",
+				zap.Int64("chat_id", c.ID),
+				zap.Error(err),
+			)
+			return errors.New("error occurred while trying to get subscriptions")
+		}
+		lines = append(lines, formatChat(c, len(subs)))
+	}
+	if len(lines) == 0 {
+		return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, "Bot wasn't added to any chat yet")
+	}
+
+	const chatsPerMessage = 30
+	for i := 0; i < len(lines); i += chatsPerMessage {
+		page := lines[i:min(i+chatsPerMessage, len(lines))]
+		response := fmt.Sprintf("Chats %v\\-%v of %v:\n\n", i+1, i+len(page), len(lines)) + strings.Join(page, "\n")
+		err = e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, response)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatChat returns a line of /chats report about the chat
+func formatChat(c *db.Chat, subscriptions int) string {
+	name := c.Title
+	if c.Username != "" {
+		name = strings.TrimSpace(name + " @" + c.Username)
+	}
+	res := render.Code(render.FormatMarkdownV2, fmt.Sprint(c.ID))
+	if name != "" {
+		res += " " + render.Escape(render.FormatMarkdownV2, name)
+	}
+	details := fmt.Sprintf("%s, %s, %v subscriptions", c.Type, c.Status, subscriptions)
+	if c.AddedBy != 0 {
+		details += fmt.Sprintf(", added by %v on %s", c.AddedBy, c.AddedAt.UTC().Format("2006-01-02"))
+	}
+	return res + " \\- " + render.Escape(render.FormatMarkdownV2, details)
+}
@@ -0,0 +1,22 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Civil/github2telegram/db"
+)
+
+func TestFormatChat(t *testing.T) {
+	addedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t,
+		"`-100123` Releases @go\\_releases \\- supergroup, administrator, 2 subscriptions, added by 42 on 2024\\-05\\-01",
+		formatChat(&db.Chat{ID: -100123, Type: "supergroup", Title: "Releases", Username: "go_releases", AddedBy: 42, AddedAt: addedAt, Status: "administrator"}, 2),
+	)
+	require.Equal(t,
+		"`-5` \\- group, kicked, 0 subscriptions",
+		formatChat(&db.Chat{ID: -5, Type: "group", Status: "kicked"}, 0),
+	)
+}
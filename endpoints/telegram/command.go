@@ -0,0 +1,355 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mymmrac/telego"
+	"github.com/pkg/errors"
+
+	"github.com/Civil/github2telegram/render"
+)
+
+type handler func(args *commandArgs, update *telego.Update) error
+
+// argument is a positional argument of the command
+type argument struct {
+	name string
+	// values are allowed values separated by |, any value is allowed if empty
+	values string
+	// optional arguments can be omitted, only arguments at the end can be optional unless they have match function
+	optional bool
+	// match recognizes optional argument that isn't at the end, it's omitted if the word doesn't match
+	match func(value string) bool
+	// rest argument takes all remaining words, it must be the last one
+	rest bool
+}
+
+// option is key=value argument of the command, options can be specified in any order
+type option struct {
+	name string
+	// usage describes the value, e.x. on|off
+	usage       string
+	description string
+}
+
+// command is a declarative description of the bot command, it's used to parse and validate arguments and to generate
+// help. Descriptions and details are MarkdownV2.
+type command struct {
+	name    string
+	f       handler
+	args    []argument
+	options []option
+	// interactive command asks for its arguments if none were given
+	interactive bool
+	description string
+	// summary is a short plain text description shown in Telegram's command menu, localized are its translations by
+	// language code
+	summary   string
+	localized map[string]string
+	// details are shown in the command's help after the options, e.x. examples
+	details string
+	// role is required to run the command, commands are shown in help and menu only to users who can run them
+	role role
+}
+
+// word is a single argument of the command and its position in the message
+type word struct {
+	value string
+	start int
+}
+
+// commandArgs are parsed arguments of the command
+type commandArgs struct {
+	values  map[string]string
+	options map[string]string
+	// rest are the words taken by rest argument
+	rest []word
+	text string
+}
+
+// get returns value of the positional argument, empty if it was omitted
+func (a *commandArgs) get(name string) string {
+	return a.values[name]
+}
+
+// empty returns true if command was called without any arguments
+func (a *commandArgs) empty() bool {
+	return len(a.values) == 0 && len(a.options) == 0 && len(a.rest) == 0
+}
+
+// restText returns the message text starting from i-th word of the rest argument as is, without unquoting
+func (a *commandArgs) restText(i int) string {
+	if i >= len(a.rest) {
+		return ""
+	}
+	return strings.TrimSpace(a.text[a.rest[i].start:])
+}
+
+func (a *commandArgs) restValues() []string {
+	res := make([]string, 0, len(a.rest))
+	for _, w := range a.rest {
+		res = append(res, w.value)
+	}
+	return res
+}
+
+// usageError is an error caused by invalid arguments, message is formatted and includes command's help
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// isQuote returns closing quote if r opens quoted string
+func isQuote(r rune) (rune, bool) {
+	switch r {
+	case '"':
+		return '"', true
+	case '\'':
+		return '\'', true
+	case '“':
+		// Mobile clients replace quotes with typographic ones
+		return '”', true
+	}
+	return 0, false
+}
+
+// splitWords splits text into words separated by any whitespace. Quoted parts of the words can contain spaces,
+// backslash escapes quotes inside double quotes only, so regular expressions can be written as is.
+func splitWords(text string) ([]word, error) {
+	var res []word
+	var cur strings.Builder
+	inWord := false
+	start := 0
+	var quote rune
+
+	runes := []rune(text)
+	offset := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		pos := offset
+		offset += len(string(r))
+
+		if quote != 0 {
+			switch {
+			case r == quote:
+				quote = 0
+			case r == '\\' && quote != '\'' && i+1 < len(runes) && (runes[i+1] == quote || runes[i+1] == '\\'):
+				i++
+				offset += len(string(runes[i]))
+				cur.WriteRune(runes[i])
+			default:
+				cur.WriteRune(r)
+			}
+			continue
+		}
+
+		if unicode.IsSpace(r) {
+			if inWord {
+				res = append(res, word{value: cur.String(), start: start})
+				cur.Reset()
+				inWord = false
+			}
+			continue
+		}
+
+		if !inWord {
+			inWord = true
+			start = pos
+		}
+		if closing, ok := isQuote(r); ok {
+			quote = closing
+			continue
+		}
+		cur.WriteRune(r)
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+	if inWord {
+		res = append(res, word{value: cur.String(), start: start})
+	}
+	return res, nil
+}
+
+// splitCommand returns the command, which is the first word of the message, and the rest of the message
+func splitCommand(text string) (string, string) {
+	text = strings.TrimLeftFunc(text, unicode.IsSpace)
+	idx := strings.IndexFunc(text, unicode.IsSpace)
+	if idx == -1 {
+		return text, ""
+	}
+	return text[:idx], text[idx:]
+}
+
+func (c *command) findOption(name string) *option {
+	for i := range c.options {
+		if c.options[i].name == name {
+			return &c.options[i]
+		}
+	}
+	return nil
+}
+
+// isOption returns true if word looks like key=value option
+func isOption(w string) (string, string, bool) {
+	key, value, ok := strings.Cut(w, "=")
+	if !ok || key == "" {
+		return "", "", false
+	}
+	for _, r := range key {
+		if !unicode.IsLetter(r) && r != '_' {
+			return "", "", false
+		}
+	}
+	return key, value, true
+}
+
+// parse parses and validates arguments of the command
+func (c *command) parse(text string) (*commandArgs, error) {
+	words, err := splitWords(text)
+	if err != nil {
+		return nil, err
+	}
+
+	args := &commandArgs{
+		values:  make(map[string]string),
+		options: make(map[string]string),
+		text:    text,
+	}
+	// Options are recognized only before the rest argument, it's taken as is
+	restIdx := -1
+	if n := len(c.args); n > 0 && c.args[n-1].rest {
+		restIdx = n - 1
+	}
+
+	var positional []word
+	for _, w := range words {
+		if len(c.options) > 0 && (restIdx < 0 || len(positional) < restIdx) {
+			if key, value, ok := isOption(w.value); ok {
+				if c.findOption(key) == nil {
+					return nil, fmt.Errorf("unknown option %s", key)
+				}
+				if _, ok := args.options[key]; ok {
+					return nil, fmt.Errorf("option %s is specified more than once", key)
+				}
+				args.options[key] = value
+				continue
+			}
+		}
+		positional = append(positional, w)
+	}
+
+	if len(positional) == 0 && len(args.options) == 0 && c.interactive {
+		return args, nil
+	}
+
+	i := 0
+	for _, a := range c.args {
+		if i >= len(positional) {
+			if !a.optional && !a.rest {
+				return nil, fmt.Errorf("%s is required", a.name)
+			}
+			continue
+		}
+		if a.rest {
+			args.rest = positional[i:]
+			i = len(positional)
+			break
+		}
+		value := positional[i].value
+		if a.match != nil && !a.match(value) {
+			continue
+		}
+		if a.values != "" && !contains(strings.Split(a.values, "|"), value) {
+			return nil, fmt.Errorf("%s must be one of %s", a.name, strings.ReplaceAll(a.values, "|", ", "))
+		}
+		args.values[a.name] = value
+		i++
+	}
+	if i < len(positional) {
+		return nil, fmt.Errorf("too many arguments, unexpected %q", positional[i].value)
+	}
+
+	return args, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// usage returns command's synopsis, e.x. /channels repo filter_name [channels] [options]
+func (c *command) usage() string {
+	parts := []string{c.name}
+	for _, a := range c.args {
+		name := a.name
+		if a.values != "" {
+			name = a.values
+		}
+		switch {
+		case a.rest:
+			parts = append(parts, "["+name+"...]")
+		case a.optional:
+			parts = append(parts, "["+name+"]")
+		default:
+			parts = append(parts, name)
+		}
+	}
+	if len(c.options) > 0 {
+		parts = append(parts, "[options]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// help returns MarkdownV2 formatted help of the command
+func (c *command) help() string {
+	res := render.Code(render.FormatMarkdownV2, c.usage()) + " \\-\\- " + c.description
+	if c.role > roleChatAdmin {
+		res += " \\(requires " + render.Escape(render.FormatMarkdownV2, c.role.String()) + " role\\)"
+	}
+	if len(c.options) > 0 {
+		res += "\n\nOptions:"
+		for _, o := range c.options {
+			res += "\n  " + render.Code(render.FormatMarkdownV2, o.name+"="+o.usage) + " \\-\\- " + o.description
+		}
+	}
+	if c.details != "" {
+		res += "\n\n" + c.details
+	}
+	return res
+}
+
+// usageError returns error that shows the reason and the help of the command
+func (c *command) usageError(err error) error {
+	return &usageError{
+		message: render.Escape(render.FormatMarkdownV2, err.Error()) + "\n\n" + c.help(),
+	}
+}
+
+// usageError returns error that shows the reason and the help of the command
+func (e *TelegramEndpoint) usageError(name string, message string) error {
+	cmd, ok := e.commands[name]
+	if !ok {
+		return errors.New(message)
+	}
+	return cmd.usageError(errors.New(message))
+}
+
+// errorMessage returns formatted message that describes the error to the user
+func errorMessage(err error) string {
+	var usage *usageError
+	if errors.As(err, &usage) {
+		return usage.message
+	}
+	return render.Escape(render.FormatMarkdownV2, err.Error())
+}
@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/mymmrac/telego"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []string
+		wantErr bool
+	}{
+		{text: "", want: nil},
+		{text: "  lomik/go-carbon   all\n^v ", want: []string{"lomik/go-carbon", "all", "^v"}},
+		{text: `all "^v1 .*"`, want: []string{"all", "^v1 .*"}},
+		{text: `constraint=">=2.0.0 <3" greater=true`, want: []string{"constraint=>=2.0.0 <3", "greater=true"}},
+		{text: `'^v\d+' "say \"hi\""`, want: []string{`^v\d+`, `say "hi"`}},
+		{text: `^v\d+\.\d+`, want: []string{`^v\d+\.\d+`}},
+		{text: "“quoted words”", want: []string{"quoted words"}},
+		{text: `"unterminated`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			words, err := splitWords(tt.text)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			var got []string
+			for _, w := range words {
+				got = append(got, w.value)
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCommandParse(t *testing.T) {
+	cmd := &command{
+		name: "/test",
+		args: []argument{
+			{name: "repo"},
+			{name: "filter_name"},
+			{name: "action", values: "chat|feed", optional: true},
+			{name: "rest", rest: true},
+		},
+		options: []option{{name: "edits", usage: "on|off"}},
+	}
+	require.Equal(t, "/test repo filter_name [chat|feed] [rest...] [options]", cmd.usage())
+
+	args, err := cmd.parse(" lomik/go-carbon  all")
+	require.NoError(t, err)
+	require.Equal(t, "lomik/go-carbon", args.get("repo"))
+	require.Equal(t, "all", args.get("filter_name"))
+	require.Equal(t, "", args.get("action"))
+	require.Empty(t, args.rest)
+
+	args, err = cmd.parse("lomik/go-carbon edits=off all chat *{{.Repo}}*  \"{{.Tag}}\"\nx=y")
+	require.NoError(t, err)
+	require.Equal(t, "off", args.options["edits"])
+	require.Equal(t, "chat", args.get("action"))
+	require.Equal(t, []string{"*{{.Repo}}*", "{{.Tag}}", "x=y"}, args.restValues())
+	require.Equal(t, "*{{.Repo}}*  \"{{.Tag}}\"\nx=y", args.restText(0))
+	require.Equal(t, "\"{{.Tag}}\"\nx=y", args.restText(1))
+
+	_, err = cmd.parse("lomik/go-carbon")
+	require.EqualError(t, err, "filter_name is required")
+
+	_, err = cmd.parse("lomik/go-carbon all group")
+	require.EqualError(t, err, "action must be one of chat, feed")
+
+	_, err = cmd.parse("lomik/go-carbon all length=10")
+	require.EqualError(t, err, "unknown option length")
+
+	_, err = cmd.parse("lomik/go-carbon all edits=on edits=off")
+	require.EqualError(t, err, "option edits is specified more than once")
+
+	noRest := &command{name: "/subscribe", args: []argument{{name: "repo"}, {name: "filter_name"}}}
+	_, err = noRest.parse("lomik/go-carbon all extra")
+	require.EqualError(t, err, `too many arguments, unexpected "extra"`)
+
+	leading := &command{name: "/subscribe", args: []argument{
+		{name: "chat", optional: true, match: func(v string) bool { return v[0] == '@' }},
+		{name: "repo"},
+		{name: "filter_name"},
+	}}
+	require.Equal(t, "/subscribe [chat] repo filter_name", leading.usage())
+	args, err = leading.parse("lomik/go-carbon all")
+	require.NoError(t, err)
+	require.Equal(t, "", args.get("chat"))
+	require.Equal(t, "lomik/go-carbon", args.get("repo"))
+	args, err = leading.parse("@releases lomik/go-carbon all")
+	require.NoError(t, err)
+	require.Equal(t, "@releases", args.get("chat"))
+	require.Equal(t, "all", args.get("filter_name"))
+	_, err = leading.parse("@releases lomik/go-carbon")
+	require.EqualError(t, err, "filter_name is required")
+
+	// Regexps that look like options are positional arguments if command has no options
+	interactive := &command{name: "/new", args: []argument{{name: "repo"}, {name: "filter_name"}, {name: "filter_regexp"}}, interactive: true}
+	args, err = interactive.parse("")
+	require.NoError(t, err)
+	require.True(t, args.empty())
+	args, err = interactive.parse("lomik/go-carbon all a=b")
+	require.NoError(t, err)
+	require.Equal(t, "a=b", args.get("filter_regexp"))
+}
+
+func TestBotCommands(t *testing.T) {
+	commands := map[string]*command{
+		"/list":         {name: "/list", summary: "list available repos", localized: map[string]string{"ru": "список доступных репозиториев"}},
+		"/help":         {name: "/help", summary: "show help"},
+		"/new":          {name: "/new", summary: "create a feed", role: roleFeedManager},
+		"/forceprocess": {name: "/forceProcess", summary: "check a repo now", role: roleAdmin},
+		"/undocumented": {name: "/undocumented"},
+	}
+	require.Equal(t, []string{"", "ru"}, menuLanguages(commands))
+
+	require.Equal(t, []telego.BotCommand{
+		{Command: "help", Description: "show help"},
+		{Command: "list", Description: "list available repos"},
+	}, botCommands(commands, roleChatAdmin, ""))
+
+	require.Equal(t, []telego.BotCommand{
+		{Command: "help", Description: "show help"},
+		{Command: "list", Description: "список доступных репозиториев"},
+		{Command: "new", Description: "create a feed"},
+	}, botCommands(commands, roleFeedManager, "ru"))
+
+	require.Len(t, botCommands(commands, roleAdmin, ""), 4)
+}
@@ -0,0 +1,270 @@
+package telegram
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/render"
+	"github.com/Civil/github2telegram/types"
+)
+
+const (
+	callbackConversation = "conv"
+
+	// conversationTimeout is how long the bot waits for the answer to its question
+	conversationTimeout = 10 * time.Minute
+	// conversationCancel is the answer that cancels any conversation
+	conversationCancel = "cancel"
+
+	// conversationTokenKey stores the token that identifies the conversation in its buttons, so buttons of the
+	// previous conversation can't answer the current one
+	conversationTokenKey = "_token"
+	// conversationMessageKey stores the message that started the conversation, questions are replied to it
+	conversationMessageKey = "_message"
+)
+
+var errConversationExpired = errors.New("command has timed out, please start it again")
+
+// conversationStep is a single question of the conversation
+type conversationStep struct {
+	// prompt returns the question formatted as MarkdownV2 and optional buttons, data of the pressed button is used as
+	// the answer
+	prompt func(state *db.Conversation) (string, [][]types.Button)
+	// handle validates the answer, stores it in the state and returns the next step. Empty step finishes the
+	// conversation.
+	handle func(state *db.Conversation, input string) (string, error)
+}
+
+// conversation is a command that asks user several questions, one by one, before doing anything. Answers are
+// stored in the database, so conversation survives restarts.
+type conversation struct {
+	first string
+	steps map[string]conversationStep
+	// finish is called when the last question is answered, returned text is sent to the user
+	finish func(state *db.Conversation) (string, error)
+}
+
+// conversationButton returns button that answers current question with the value. Data are bound to the
+// conversation and its step by askConversationStep.
+func conversationButton(text, value string) types.Button {
+	return types.Button{Text: text, Data: value}
+}
+
+// conversationCallback returns callback data of the button that answers current step of the conversation
+func conversationCallback(state *db.Conversation, value string) string {
+	return callbackData(callbackConversation, state.Data[conversationTokenKey]+":"+state.Step+":"+value)
+}
+
+// conversationAnswer returns the answer encoded in the callback data, if the button belongs to the current step
+// of the conversation
+func conversationAnswer(state *db.Conversation, arg string) (string, bool) {
+	parts := strings.SplitN(arg, ":", 3)
+	if len(parts) != 3 || parts[0] != state.Data[conversationTokenKey] || parts[1] != state.Step {
+		return "", false
+	}
+	return parts[2], true
+}
+
+// startConversation starts conversation for the command, replacing the one user had in the chat. Data are answers
+// that are already known, e.x. arguments of the command.
+func (e *TelegramEndpoint) startConversation(command string, chatID, userID int64, messageID int, data map[string]string) error {
+	conv, ok := e.conversations[command]
+	if !ok {
+		return errors.New("unknown command " + command)
+	}
+
+	state := &db.Conversation{
+		ChatID:  chatID,
+		UserID:  userID,
+		Command: command,
+		Step:    conv.first,
+		Data:    data,
+	}
+	if state.Data == nil {
+		state.Data = make(map[string]string)
+	}
+	state.Data[conversationTokenKey] = strconv.FormatInt(time.Now().UnixNano(), 36)
+	if messageID != 0 {
+		state.Data[conversationMessageKey] = strconv.Itoa(messageID)
+	}
+	return e.askConversationStep(state, messageID)
+}
+
+// askConversationStep saves the state and asks the question of the current step. Questions without buttons force
+// the reply of the user, as bots in privacy mode see only replies to their messages in groups.
+func (e *TelegramEndpoint) askConversationStep(state *db.Conversation, messageID int) error {
+	step := e.conversations[state.Command].steps[state.Step]
+
+	state.ExpiresAt = time.Now().Add(conversationTimeout)
+	err := e.db.SaveConversation(state)
+	if err != nil {
+		e.logger.Error("failed to save conversation",
+			zap.Int64("chat_id", state.ChatID),
+			zap.Int64("user_id", state.UserID),
+			zap.String("command", state.Command),
+			zap.Error(err),
+		)
+		return errors.New("error occurred while trying to save command state")
+	}
+
+	if messageID == 0 {
+		messageID, _ = strconv.Atoi(state.Data[conversationMessageKey])
+	}
+
+	text, buttons := step.prompt(state)
+	if len(buttons) == 0 {
+		text += "\n\nReply to this message or send /cancel"
+		return e.sendForceReply(state.ChatID, messageID, text)
+	}
+
+	buttons = append(buttons, []types.Button{conversationButton("Cancel", conversationCancel)})
+	for _, row := range buttons {
+		for i := range row {
+			row[i].Data = conversationCallback(state, row[i].Data)
+		}
+	}
+	return e.sendMessageWithButtons(state.ChatID, messageID, text, buttons)
+}
+
+// activeConversation returns conversation user has in the chat, nil if there is none
+func (e *TelegramEndpoint) activeConversation(chatID, userID int64) (*db.Conversation, error) {
+	state, err := e.db.GetConversation(chatID, userID)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conv, ok := e.conversations[state.Command]
+	if ok {
+		_, ok = conv.steps[state.Step]
+	}
+	if !ok || state.ExpiresAt.Before(time.Now()) {
+		e.removeConversation(state)
+		if !ok {
+			return nil, nil
+		}
+		return nil, errConversationExpired
+	}
+	return state, nil
+}
+
+func (e *TelegramEndpoint) removeConversation(state *db.Conversation) {
+	err := e.db.RemoveConversation(state.ChatID, state.UserID)
+	if err != nil {
+		e.logger.Error("failed to remove conversation",
+			zap.Int64("chat_id", state.ChatID),
+			zap.Int64("user_id", state.UserID),
+			zap.String("command", state.Command),
+			zap.Error(err),
+		)
+	}
+}
+
+// continueConversation passes the answer to the current step and asks the next question or finishes the
+// conversation. Invalid answer is reported and the question is asked again.
+func (e *TelegramEndpoint) continueConversation(state *db.Conversation, input string, messageID int) error {
+	if input == conversationCancel {
+		e.removeConversation(state)
+		return e.sendMessage(state.ChatID, messageID, render.Escape(render.FormatMarkdownV2, state.Command)+" cancelled")
+	}
+
+	conv := e.conversations[state.Command]
+	next, err := conv.steps[state.Step].handle(state, input)
+	if err != nil {
+		err = e.sendMessage(state.ChatID, messageID, render.Escape(render.FormatMarkdownV2, err.Error()))
+		if err != nil {
+			return err
+		}
+		return e.askConversationStep(state, messageID)
+	}
+
+	if next != "" {
+		state.Step = next
+		return e.askConversationStep(state, messageID)
+	}
+
+	e.removeConversation(state)
+	text, err := conv.finish(state)
+	if err != nil {
+		return err
+	}
+	return e.sendMessage(state.ChatID, messageID, text)
+}
+
+// handleConversationMessage treats the message as an answer to the question, if user has active conversation
+func (e *TelegramEndpoint) handleConversationMessage(update *telego.Update) error {
+	if update.Message.From == nil || update.Message.Text == "" {
+		return nil
+	}
+
+	state, err := e.activeConversation(update.Message.Chat.ID, update.Message.From.ID)
+	if err != nil || state == nil {
+		return err
+	}
+	return e.continueConversation(state, update.Message.Text, update.Message.MessageID)
+}
+
+func (e *TelegramEndpoint) handlerCancel(_ *commandArgs, update *telego.Update) error {
+	if update.Message.From == nil {
+		return errors.New("nothing to cancel")
+	}
+	state, err := e.activeConversation(update.Message.Chat.ID, update.Message.From.ID)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return errors.New("nothing to cancel")
+	}
+	return e.continueConversation(state, conversationCancel, update.Message.MessageID)
+}
+
+// callbackConversationAnswer handles buttons of the conversation questions
+func (e *TelegramEndpoint) callbackConversationAnswer(arg string, query *telego.CallbackQuery) (string, error) {
+	if query.Message == nil {
+		return "", errors.New("message is too old")
+	}
+	chatID := query.Message.GetChat().ID
+
+	state, err := e.activeConversation(chatID, query.From.ID)
+	if err != nil {
+		return "", err
+	}
+	if state == nil {
+		return "", errors.New("this question was asked to someone else or is already answered")
+	}
+	answer, ok := conversationAnswer(state, arg)
+	if !ok {
+		return "", errors.New("this question is already answered, please use buttons of the last one")
+	}
+
+	// Question is answered, its buttons can't be pressed again
+	params := &telego.EditMessageReplyMarkupParams{
+		ChatID:    tu.ID(chatID),
+		MessageID: query.Message.GetMessageID(),
+	}
+	_, err = e.api.EditMessageReplyMarkup(params)
+	if err != nil {
+		e.logger.Warn("failed to remove buttons",
+			zap.Int64("chat_id", chatID),
+			zap.Error(err),
+		)
+	}
+
+	err = e.continueConversation(state, answer, 0)
+	if err != nil {
+		err2 := e.sendMessage(chatID, 0, render.Escape(render.FormatMarkdownV2, err.Error()))
+		if err2 != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,35 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Civil/github2telegram/db"
+)
+
+func TestConversationAnswer(t *testing.T) {
+	deleteA := &db.Conversation{Command: "/delete", Step: deleteStepConfirm, Data: map[string]string{conversationTokenKey: "a1"}}
+	deleteB := &db.Conversation{Command: "/delete", Step: deleteStepConfirm, Data: map[string]string{conversationTokenKey: "b2"}}
+
+	data := conversationCallback(deleteA, deleteConfirm)
+	prefix, arg, _ := strings.Cut(data, ":")
+	require.Equal(t, callbackConversation, prefix)
+
+	answer, ok := conversationAnswer(deleteA, arg)
+	require.True(t, ok)
+	require.Equal(t, deleteConfirm, answer)
+
+	// Button of /delete A must not confirm /delete B
+	_, ok = conversationAnswer(deleteB, arg)
+	require.False(t, ok)
+
+	// Button of the answered question must not answer the next one
+	deleteA.Step = "other"
+	_, ok = conversationAnswer(deleteA, arg)
+	require.False(t, ok)
+
+	_, ok = conversationAnswer(deleteB, deleteConfirm)
+	require.False(t, ok)
+}
@@ -0,0 +1,284 @@
+package telegram
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/feeds"
+	"github.com/Civil/github2telegram/render"
+	"github.com/Civil/github2telegram/types"
+)
+
+const (
+	deleteStepConfirm = "confirm"
+	deleteConfirm     = "delete"
+)
+
+// notifySubscribers sends message to all chats subscribed to the filter
+func (e *TelegramEndpoint) notifySubscribers(logger *zap.Logger, subs []*db.Subscription, message string) {
+	for _, sub := range subs {
+		err := e.sendThreadMessage(sub.ChatID, sub.ThreadID, message)
+		if err != nil {
+			logger.Warn("failed to notify subscriber",
+				zap.Int64("chat_id", sub.ChatID),
+				zap.String("url", sub.Url),
+				zap.String("filter_name", sub.Filter),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+func (e *TelegramEndpoint) handlerEdit(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "edit"))
+	url := args.get("repo")
+	filterName := args.get("filter_name")
+	if !isFilterExists(url, filterName) {
+		return errors.New("unknown combination of url and filter, use /list to get list of possible feeds")
+	}
+
+	re, err := regexp.Compile(args.get("filter_regexp"))
+	if err != nil {
+		return errors.Wrap(err, "invalid regexp")
+	}
+
+	err = e.db.SetFeedFilter(filterName, url, feeds.FeedURL(url), re.String())
+	if err != nil {
+		logger.Error("error updating filter",
+			zap.String("url", url),
+			zap.String("filter_name", filterName),
+			zap.Error(err),
+		)
+		return errors.New("error occurred while trying to update filter")
+	}
+	feeds.UpdateFilterRegex(url, filterName, re)
+
+	subs, err := e.db.GetSubscriptions(TelegramEndpointName, url, filterName)
+	if err != nil {
+		logger.Error("error getting subscriptions",
+			zap.String("url", url),
+			zap.String("filter_name", filterName),
+			zap.Error(err),
+		)
+	}
+	e.notifySubscribers(logger, subs, "filter "+code(filterName)+" of "+code(url)+" was changed, now it announces tags matching "+code(re.String()))
+
+	return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID,
+		render.Escape(render.FormatMarkdownV2, fmt.Sprintf("filter updated, %v subscribed chats were notified", len(subs))))
+}
+
+func (e *TelegramEndpoint) handlerDelete(args *commandArgs, update *telego.Update) error {
+	url := args.get("repo")
+	filterName := args.get("filter_name")
+	if !isFilterExists(url, filterName) {
+		return errors.New("unknown combination of url and filter, use /list to get list of possible feeds")
+	}
+
+	migrate := args.options["migrate"]
+	if migrate == filterName {
+		return e.usageError("/delete", "subscriptions can't be moved to the filter that is deleted")
+	}
+	if migrate != "" && !isFilterExists(url, migrate) {
+		return errors.New("filter " + migrate + " doesn't exist, subscriptions can be moved only to another filter of the same repo")
+	}
+
+	return e.startConversation("/delete", update.Message.Chat.ID, update.Message.From.ID, update.Message.MessageID, map[string]string{
+		"repo":    url,
+		"filter":  filterName,
+		"migrate": migrate,
+	})
+}
+
+// deleteConversation asks to confirm deletion of the filter
+func (e *TelegramEndpoint) deleteConversation() *conversation {
+	return &conversation{
+		first: deleteStepConfirm,
+		steps: map[string]conversationStep{
+			deleteStepConfirm: {
+				prompt: func(state *db.Conversation) (string, [][]types.Button) {
+					url := state.Data["repo"]
+					filterName := state.Data["filter"]
+					subs, _ := e.db.GetSubscriptions(TelegramEndpointName, url, filterName)
+					text := "Delete filter " + code(filterName) + " of " + code(url) + "? " +
+						render.Escape(render.FormatMarkdownV2, fmt.Sprintf("%v chats are subscribed to it, ", len(subs)))
+					if migrate := state.Data["migrate"]; migrate != "" {
+						text += "they will be moved to " + code(migrate)
+					} else {
+						text += "they will be unsubscribed"
+					}
+					return text, [][]types.Button{{conversationButton("Delete", deleteConfirm)}}
+				},
+				handle: func(_ *db.Conversation, input string) (string, error) {
+					if input != deleteConfirm {
+						return "", errors.New("please use one of the buttons")
+					}
+					return "", nil
+				},
+			},
+		},
+		finish: func(state *db.Conversation) (string, error) {
+			return e.deleteFilter(state.Data["repo"], state.Data["filter"], state.Data["migrate"])
+		},
+	}
+}
+
+// deleteFilter removes the filter and its rules, stops its processing and moves or removes its subscriptions.
+// Subscribed chats are notified.
+func (e *TelegramEndpoint) deleteFilter(url, filterName, migrate string) (string, error) {
+	logger := e.logger.With(
+		zap.String("handler", "delete"),
+		zap.String("url", url),
+		zap.String("filter_name", filterName),
+	)
+
+	filter := findFilter(url, filterName)
+	if filter == nil {
+		return "", errors.New("filter doesn't exist anymore")
+	}
+	if migrate != "" && !isFilterExists(url, migrate) {
+		return "", errors.New("filter " + migrate + " doesn't exist anymore")
+	}
+	configs.Config.RLock()
+	regex := filter.Filter
+	messagePattern := filter.MessagePattern
+	configs.Config.RUnlock()
+
+	subs, err := e.db.GetSubscriptions(TelegramEndpointName, url, filterName)
+	if err != nil {
+		logger.Error("error getting subscriptions",
+			zap.Error(err),
+		)
+	}
+
+	if migrate != "" {
+		err = e.db.MoveSubscriptions(url, filterName, migrate)
+	} else {
+		err = e.db.RemoveSubscriptions(url, filterName)
+	}
+	if err != nil {
+		logger.Error("error updating subscriptions",
+			zap.String("migrate", migrate),
+			zap.Error(err),
+		)
+		return "", errors.New("error occurred while trying to update subscriptions")
+	}
+
+	err = e.db.RemoveFeed(filterName, url, regex, messagePattern)
+	if err != nil {
+		return "", errors.New("error occurred while trying to delete filter")
+	}
+	err = e.db.SetFilterRules(url, filterName, nil)
+	if err != nil {
+		logger.Warn("error removing filter rules",
+			zap.Error(err),
+		)
+	}
+	feeds.RemoveFilter(url, filterName)
+
+	message := "filter " + code(filterName) + " of " + code(url) + " was deleted, "
+	if migrate != "" {
+		message += "this chat is now subscribed to " + code(migrate) + " instead"
+	} else {
+		message += "this chat is unsubscribed from it"
+	}
+	e.notifySubscribers(logger, subs, message)
+
+	return render.Escape(render.FormatMarkdownV2, fmt.Sprintf("filter deleted, %v subscribed chats were notified", len(subs))), nil
+}
+
+// testFilter returns filter to test, either a copy of the existing one or a new one with given regexp
+func testFilter(url, filterName, filterRegexp string) (*configs.FiltersConfig, error) {
+	filter := &configs.FiltersConfig{Name: "test"}
+	if filterName != "" {
+		filter = feeds.FilterCopy(url, filterName)
+		if filter == nil {
+			return nil, errors.New("unknown combination of url and filter, use /list to get list of possible feeds")
+		}
+	}
+	if filterRegexp != "" {
+		re, err := regexp.Compile(filterRegexp)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid regexp")
+		}
+		filter.Filter = filterRegexp
+		filter.FilterRegex = re
+	}
+	return filter, nil
+}
+
+func formatDryRun(url string, filter *configs.FiltersConfig, results []*feeds.DryRunResult) string {
+	lines := make([]string, 0, len(results))
+	matched := 0
+	for _, r := range results {
+		if r.Update != nil {
+			matched++
+			lines = append(lines, "✅ "+code(r.Tag))
+		} else {
+			lines = append(lines, "❌ "+code(r.Tag)+" \\- "+render.Escape(render.FormatMarkdownV2, r.Reason))
+		}
+	}
+
+	text := "Recent releases of " + code(url) + " tested against " + code(filter.Filter) + ", oldest first:\n" + strings.Join(lines, "\n")
+	switch {
+	case len(results) == 0:
+		text = "repo " + code(url) + " has no releases"
+	case matched == 0:
+		text += "\n\nnone of the releases matched"
+	default:
+		text += render.Escape(render.FormatMarkdownV2, fmt.Sprintf("\n\n%v of %v releases matched, latest one would be announced as:", matched, len(results)))
+	}
+	return text
+}
+
+func (e *TelegramEndpoint) handlerTest(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "test"))
+	url := args.get("repo")
+	filterName := args.options["filter"]
+	filterRegexp := args.get("filter_regexp")
+	if filterName == "" && filterRegexp == "" {
+		return e.usageError("/test", "either filter_regexp or filter option is required")
+	}
+	filter, err := testFilter(url, filterName, filterRegexp)
+	if err != nil {
+		return err
+	}
+
+	results, err := feeds.DryRun(url, filter)
+	if err != nil {
+		logger.Warn("failed to fetch releases",
+			zap.String("url", url),
+			zap.Error(err),
+		)
+		return errors.New("failed to fetch releases of " + url + ", check that repo exists")
+	}
+
+	chatID := update.Message.Chat.ID
+	err = e.sendMessage(chatID, update.Message.MessageID, formatDryRun(url, filter, results))
+	if err != nil {
+		return err
+	}
+
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i].Update == nil {
+			continue
+		}
+		message, err := render.Render(results[i].Update, messageTemplate("", filter.MessagePattern), e.format)
+		if err != nil {
+			return errors.Wrap(err, "failed to render template")
+		}
+		// Preview is sent without fallback, so formatting errors are reported back
+		return e.sendNotification(&types.NotificationMessage{
+			ChatID:   chatID,
+			ThreadID: messageThread(update.Message),
+			Message:  message,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package telegram
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/feeds"
+)
+
+const (
+	// inlineResults is the maximum number of releases returned for inline query
+	inlineResults = 10
+	// inlineCacheTime is how long Telegram caches results of inline query, in seconds
+	inlineCacheTime = 60
+)
+
+// handleInlineQuery answers `@bot owner/repo` queries with the latest releases of the repo, so they can be posted to
+// any chat. Only releases already seen by the poller are returned, the feed is not fetched.
+func (e *TelegramEndpoint) handleInlineQuery(logger *zap.Logger, query *telego.InlineQuery) {
+	repo := strings.TrimSpace(query.Query)
+	logger = logger.With(
+		zap.String("handler", "inline"),
+		zap.String("from", query.From.Username),
+		zap.String("query", repo),
+	)
+	logger.Debug("got inline query")
+
+	// Empty list must be sent as well, otherwise client keeps waiting for results
+	results := make([]telego.InlineQueryResult, 0, inlineResults)
+	if e.isRepoNameValid(repo) == nil {
+		updates, err := feeds.CachedReleases(e.db, repo, inlineResults)
+		if err != nil {
+			logger.Warn("failed to get releases",
+				zap.Error(err),
+			)
+		}
+
+		for i, update := range updates {
+			notification := e.renderUpdate(logger, update, &db.Subscription{})
+			if len(notification.Messages) == 0 {
+				continue
+			}
+			article := tu.ResultArticle(
+				strconv.Itoa(i),
+				update.Repo+" "+update.Tag,
+				tu.TextMessage(notification.Messages[0].Text).WithParseMode(parseMode(e.format)),
+			).WithDescription(update.Title)
+			if buttons := notificationButtons(update, &db.Subscription{}); len(buttons) > 0 {
+				article = article.WithReplyMarkup(inlineKeyboard(buttons))
+			}
+			results = append(results, article)
+		}
+	}
+
+	err := e.api.AnswerInlineQuery(tu.InlineQuery(query.ID, results...).WithCacheTime(inlineCacheTime))
+	if err != nil {
+		logger.Error("failed to answer inline query",
+			zap.Error(err),
+		)
+	}
+}
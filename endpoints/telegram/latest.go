@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"regexp"
+
+	"github.com/mymmrac/telego"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/feeds"
+)
+
+var anyRelease = regexp.MustCompile(".*")
+
+func (e *TelegramEndpoint) handlerLatest(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "latest"))
+	url := args.get("repo")
+	filterName := args.get("filter_name")
+	chatID := update.Message.Chat.ID
+
+	filter := &configs.FiltersConfig{Filter: anyRelease.String(), FilterRegex: anyRelease}
+	// Chat's subscription settings are used if it's subscribed, so release looks exactly like a notification
+	sub := &db.Subscription{ChatID: chatID, ThreadID: messageThread(update.Message)}
+	if filterName != "" {
+		filter = feeds.FilterCopy(url, filterName)
+		if filter == nil {
+			return errors.New("unknown combination of url and filter, use /list to get list of possible feeds")
+		}
+		s, err := e.db.GetSubscription(TelegramEndpointName, url, filterName, chatID)
+		if err == nil {
+			// Release is shown where it was requested, not in the topic notifications are sent to
+			s.ThreadID = sub.ThreadID
+			sub = s
+		}
+	}
+
+	latest, err := feeds.Latest(e.db, url, filter, 1, nil)
+	if err != nil {
+		logger.Warn("failed to get releases",
+			zap.String("url", url),
+			zap.Error(err),
+		)
+		return errors.New("failed to get releases of " + url + ", check that repo exists")
+	}
+	if len(latest) == 0 {
+		return errors.New("no releases of " + url + " match the filter")
+	}
+
+	return e.sendUpdate(logger, latest[0], sub, false)
+}
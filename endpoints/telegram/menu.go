@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"go.uber.org/zap"
+)
+
+// botCommands returns commands available to the role for Telegram's command menu in the language, English summaries
+// are used if there is no translation
+func botCommands(commands map[string]*command, r role, language string) []telego.BotCommand {
+	res := make([]telego.BotCommand, 0, len(commands))
+	for name, c := range commands {
+		if c.summary == "" || c.role > r {
+			continue
+		}
+		description := c.summary
+		if localized, ok := c.localized[language]; ok {
+			description = localized
+		}
+		res = append(res, telego.BotCommand{
+			Command:     strings.TrimPrefix(name, "/"),
+			Description: description,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Command < res[j].Command
+	})
+	return res
+}
+
+// menuLanguages returns languages commands are translated to, empty one is the default
+func menuLanguages(commands map[string]*command) []string {
+	res := []string{""}
+	for _, c := range commands {
+		for language := range c.localized {
+			if !contains(res, language) {
+				res = append(res, language)
+			}
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// setCommands replaces command menu of the scope in all languages
+func (e *TelegramEndpoint) setCommands(scope telego.BotCommandScope, r role) error {
+	for _, language := range menuLanguages(e.commands) {
+		err := e.api.SetMyCommands(&telego.SetMyCommandsParams{
+			Commands:     botCommands(e.commands, r, language),
+			Scope:        scope,
+			LanguageCode: language,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registerCommands populates command menu for private chats and group admins. Other group members don't get the menu,
+// as they can't change subscriptions.
+func (e *TelegramEndpoint) registerCommands() {
+	for _, scope := range []telego.BotCommandScope{tu.ScopeAllPrivateChats(), tu.ScopeAllChatAdministrators()} {
+		err := e.setCommands(scope, roleChatAdmin)
+		if err != nil {
+			e.logger.Warn("failed to register bot commands",
+				zap.String("scope", scope.ScopeType()),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// registerRoleCommands shows commands of the role in the private chat with the user, or resets the menu to the
+// default one if user doesn't have any role anymore
+func (e *TelegramEndpoint) registerRoleCommands(chatID int64, r role) {
+	e.adminMenus[chatID] = true
+	scope := tu.ScopeChat(tu.ID(chatID))
+
+	var err error
+	if r > roleChatAdmin {
+		err = e.setCommands(scope, r)
+	} else {
+		for _, language := range menuLanguages(e.commands) {
+			err = e.api.DeleteMyCommands(&telego.DeleteMyCommandsParams{Scope: scope, LanguageCode: language})
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		e.logger.Warn("failed to register role commands",
+			zap.Int64("chat_id", chatID),
+			zap.Stringer("role", r),
+			zap.Error(err),
+		)
+	}
+}
+
+// registerAdminCommands shows commands of the user's role when user writes to the bot for the first time, admin
+// specified by username isn't known before that. Called only from Process.
+func (e *TelegramEndpoint) registerAdminCommands(message *telego.Message) {
+	if message.Chat.Type != telego.ChatTypePrivate || e.adminMenus[message.Chat.ID] {
+		return
+	}
+	if r := e.grantedRole(message.From); r > roleChatAdmin {
+		e.registerRoleCommands(message.Chat.ID, r)
+	}
+}
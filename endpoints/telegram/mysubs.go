@@ -0,0 +1,186 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/render"
+	"github.com/Civil/github2telegram/types"
+)
+
+const (
+	callbackSubscriptionsPage        = "subs"
+	callbackSubscriptionsUnsubscribe = "subsunsub"
+
+	subscriptionsPerPage = 10
+)
+
+// lastTag returns the tag of the last release announced by the filter
+func lastTag(url, filterName string) string {
+	filter := findFilter(url, filterName)
+	if filter == nil {
+		return ""
+	}
+	configs.Config.RLock()
+	defer configs.Config.RUnlock()
+	return filter.LastTag
+}
+
+func formatSubscription(n int, sub *db.Subscription) string {
+	silent := "none"
+	if len(sub.SilentChannels) > 0 {
+		silent = types.JoinChannels(sub.SilentChannels)
+	}
+	last := lastTag(sub.Url, sub.Filter)
+	if last == "" {
+		last = "none"
+	}
+
+	res := fmt.Sprintf("%v\\. %s %s\n    channels: %s, silent: %s, last release: %s",
+		n,
+		render.Code(render.FormatMarkdownV2, sub.Url),
+		render.Code(render.FormatMarkdownV2, sub.Filter),
+		render.Code(render.FormatMarkdownV2, formatChannels(sub.Channels)),
+		render.Code(render.FormatMarkdownV2, silent),
+		render.Code(render.FormatMarkdownV2, last),
+	)
+	if mute := formatMute(sub.MutedUntil); mute != "off" {
+		res += ", muted until " + render.Escape(render.FormatMarkdownV2, mute)
+	}
+	return res
+}
+
+// subscriptionsPage returns page of the chat's subscriptions list with buttons to unsubscribe and to switch pages.
+// Page is adjusted if it's out of range.
+func (e *TelegramEndpoint) subscriptionsPage(chatID int64, page int) (string, [][]types.Button, error) {
+	subs, err := e.db.GetChatSubscriptions(TelegramEndpointName, chatID)
+	if err != nil {
+		e.logger.Error("error getting subscriptions",
+			zap.Int64("chat_id", chatID),
+			zap.Error(err),
+		)
+		return "", nil, errors.New("error occurred while trying to get subscriptions")
+	}
+	if len(subs) == 0 {
+		return "this chat has no subscriptions, use /list to get list of possible feeds and /subscribe to subscribe to one of them", nil, nil
+	}
+
+	pages := (len(subs) + subscriptionsPerPage - 1) / subscriptionsPerPage
+	page = max(1, min(page, pages))
+	first := (page - 1) * subscriptionsPerPage
+	last := min(first+subscriptionsPerPage, len(subs))
+
+	lines := make([]string, 0, last-first)
+	var buttons [][]types.Button
+	for i, sub := range subs[first:last] {
+		n := first + i + 1
+		lines = append(lines, formatSubscription(n, sub))
+		buttons = append(buttons, []types.Button{{
+			Text: fmt.Sprintf("Unsubscribe %v. %s %s", n, sub.Url, sub.Filter),
+			Data: callbackData(callbackSubscriptionsUnsubscribe, strconv.FormatInt(sub.ID, 10)+":"+strconv.Itoa(page)),
+		}})
+	}
+
+	var navigation []types.Button
+	if page > 1 {
+		navigation = append(navigation, types.Button{Text: "« Previous", Data: callbackData(callbackSubscriptionsPage, strconv.Itoa(page-1))})
+	}
+	if page < pages {
+		navigation = append(navigation, types.Button{Text: "Next »", Data: callbackData(callbackSubscriptionsPage, strconv.Itoa(page+1))})
+	}
+	if len(navigation) > 0 {
+		buttons = append(buttons, navigation)
+	}
+
+	text := fmt.Sprintf("Subscriptions of this chat %v/%v:\n\n%s", page, pages, strings.Join(lines, "\n"))
+	return text, buttons, nil
+}
+
+func (e *TelegramEndpoint) handlerMySubscriptions(args *commandArgs, update *telego.Update) error {
+	page := 1
+	if p := args.get("page"); p != "" {
+		var err error
+		page, err = strconv.Atoi(p)
+		if err != nil || page < 1 {
+			return e.usageError("/mysubs", "page must be a positive number")
+		}
+	}
+
+	chatID := update.Message.Chat.ID
+	text, buttons, err := e.subscriptionsPage(chatID, page)
+	if err != nil {
+		return err
+	}
+	return e.sendMessageWithButtons(chatID, update.Message.MessageID, text, buttons)
+}
+
+// showSubscriptionsPage replaces subscriptions list in the message with another page
+func (e *TelegramEndpoint) showSubscriptionsPage(query *telego.CallbackQuery, page int) error {
+	chatID := query.Message.GetChat().ID
+	text, buttons, err := e.subscriptionsPage(chatID, page)
+	if err != nil {
+		return err
+	}
+
+	params := &telego.EditMessageTextParams{
+		ChatID:    tu.ID(chatID),
+		MessageID: query.Message.GetMessageID(),
+		Text:      text,
+		ParseMode: telego.ModeMarkdownV2,
+	}
+	if len(buttons) > 0 {
+		params.ReplyMarkup = inlineKeyboard(buttons)
+	}
+	_, err = e.api.EditMessageText(params)
+	if err != nil {
+		e.logger.Error("failed to update subscriptions list",
+			zap.Int64("chat_id", chatID),
+			zap.Error(err),
+		)
+		return errors.New("failed to update subscriptions list")
+	}
+	return nil
+}
+
+func (e *TelegramEndpoint) callbackSubscriptionsPage(arg string, query *telego.CallbackQuery) (string, error) {
+	if query.Message == nil {
+		return "", errors.New("message is too old")
+	}
+	if !e.hasRole(query.Message.GetChat(), &query.From, roleChatAdmin) {
+		return "", errUnauthorized
+	}
+
+	page, err := strconv.Atoi(arg)
+	if err != nil {
+		return "", errors.New("invalid button")
+	}
+	return "", e.showSubscriptionsPage(query, page)
+}
+
+func (e *TelegramEndpoint) callbackSubscriptionsUnsubscribe(arg string, query *telego.CallbackQuery) (string, error) {
+	logger := e.logger.With(zap.String("handler", "callbackSubscriptionsUnsubscribe"))
+	id, pageArg, _ := strings.Cut(arg, ":")
+	page, err := strconv.Atoi(pageArg)
+	if err != nil {
+		return "", errors.New("invalid button")
+	}
+
+	sub, err := e.callbackSubscription(id, query)
+	if err != nil {
+		return "", err
+	}
+
+	err = e.unsubscribe(logger, sub.ChatID, sub.Url, sub.Filter)
+	if err != nil {
+		return "", err
+	}
+	return "unsubscribed from " + sub.Url + " " + sub.Filter, e.showSubscriptionsPage(query, page)
+}
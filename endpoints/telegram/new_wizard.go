@@ -0,0 +1,172 @@
+package telegram
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/render"
+	"github.com/Civil/github2telegram/types"
+)
+
+const (
+	newStepSource  = "source"
+	newStepRepo    = "repo"
+	newStepPreset  = "preset"
+	newStepRegexp  = "regexp"
+	newStepName    = "name"
+	newStepConfirm = "confirm"
+
+	newPresetCustom = "custom"
+	newConfirm      = "create"
+)
+
+// sourceType is the kind of the feed that can be created
+type sourceType struct {
+	id   string
+	name string
+}
+
+// sourceTypes are offered by /new wizard, only GitHub releases are supported so far
+var sourceTypes = []sourceType{
+	{id: "github", name: "GitHub releases"},
+}
+
+// filterPreset is commonly used filter offered by /new wizard
+type filterPreset struct {
+	name   string
+	regexp string
+	title  string
+}
+
+var filterPresets = []filterPreset{
+	{name: "all", regexp: ".*", title: "All releases"},
+	{name: "stable", regexp: `^v?[0-9]+\.[0-9]+\.[0-9]+$`, title: "Stable releases (v1.2.3)"},
+	{name: "v", regexp: "^v", title: "Tags starting with v"},
+}
+
+func findPreset(name string) *filterPreset {
+	for i := range filterPresets {
+		if filterPresets[i].name == name {
+			return &filterPresets[i]
+		}
+	}
+	return nil
+}
+
+func code(s string) string {
+	return render.Code(render.FormatMarkdownV2, s)
+}
+
+// newConversation is /new command that asks for all the arguments one by one instead of requiring them in the
+// exact order
+func (e *TelegramEndpoint) newConversation() *conversation {
+	return &conversation{
+		first: newStepSource,
+		steps: map[string]conversationStep{
+			newStepSource: {
+				prompt: func(_ *db.Conversation) (string, [][]types.Button) {
+					var row []types.Button
+					for _, s := range sourceTypes {
+						row = append(row, conversationButton(s.name, s.id))
+					}
+					return "What do you want to follow?", [][]types.Button{row}
+				},
+				handle: func(state *db.Conversation, input string) (string, error) {
+					for _, s := range sourceTypes {
+						if s.id == input || s.name == input {
+							state.Data[newStepSource] = s.id
+							return newStepRepo, nil
+						}
+					}
+					return "", errors.New("unknown source type, please use one of the buttons")
+				},
+			},
+			newStepRepo: {
+				prompt: func(_ *db.Conversation) (string, [][]types.Button) {
+					return "Send repository name, e\\.x\\. " + code("lomik/go-carbon"), nil
+				},
+				handle: func(state *db.Conversation, input string) (string, error) {
+					err := e.isRepoNameValid(input)
+					if err != nil {
+						return "", errors.Wrap(err, "invalid repo_name")
+					}
+					state.Data[newStepRepo] = input
+					return newStepPreset, nil
+				},
+			},
+			newStepPreset: {
+				prompt: func(_ *db.Conversation) (string, [][]types.Button) {
+					var buttons [][]types.Button
+					for _, p := range filterPresets {
+						buttons = append(buttons, []types.Button{conversationButton(p.title, p.name)})
+					}
+					buttons = append(buttons, []types.Button{conversationButton("Custom regexp", newPresetCustom)})
+					return "Which releases should be announced?", buttons
+				},
+				handle: func(state *db.Conversation, input string) (string, error) {
+					if input == newPresetCustom {
+						return newStepRegexp, nil
+					}
+					p := findPreset(input)
+					if p == nil {
+						return "", errors.New("unknown filter preset, please use one of the buttons")
+					}
+					state.Data[newStepPreset] = p.name
+					state.Data[newStepRegexp] = p.regexp
+					return newStepName, nil
+				},
+			},
+			newStepRegexp: {
+				prompt: func(_ *db.Conversation) (string, [][]types.Button) {
+					return "Send regexp that tags must match, e\\.x\\. " + code("^v2\\.") + " for 2\\.x releases", nil
+				},
+				handle: func(state *db.Conversation, input string) (string, error) {
+					_, err := regexp.Compile(input)
+					if err != nil {
+						return "", errors.Wrap(err, "invalid regexp")
+					}
+					state.Data[newStepRegexp] = input
+					return newStepName, nil
+				},
+			},
+			newStepName: {
+				prompt: func(state *db.Conversation) (string, [][]types.Button) {
+					var buttons [][]types.Button
+					if preset := state.Data[newStepPreset]; preset != "" && !isFilterExists(state.Data[newStepRepo], preset) {
+						buttons = append(buttons, []types.Button{conversationButton(preset, preset)})
+					}
+					return "Send name of the filter, it is used to subscribe to it", buttons
+				},
+				handle: func(state *db.Conversation, input string) (string, error) {
+					err := e.isFilterNameValid(input)
+					if err != nil {
+						return "", errors.Wrap(err, "invalid filter_name")
+					}
+					if isFilterExists(state.Data[newStepRepo], input) {
+						return "", errors.New("filter with that name already exists")
+					}
+					state.Data[newStepName] = input
+					return newStepConfirm, nil
+				},
+			},
+			newStepConfirm: {
+				prompt: func(state *db.Conversation) (string, [][]types.Button) {
+					text := "Create filter " + code(state.Data[newStepName]) + " for " + code(state.Data[newStepRepo]) +
+						" that announces tags matching " + code(state.Data[newStepRegexp]) + "?"
+					return text, [][]types.Button{{conversationButton("Create", newConfirm)}}
+				},
+				handle: func(_ *db.Conversation, input string) (string, error) {
+					if input != newConfirm {
+						return "", errors.New("please use one of the buttons")
+					}
+					return "", nil
+				},
+			},
+		},
+		finish: func(state *db.Conversation) (string, error) {
+			return e.createFeed(state.Data[newStepRepo], state.Data[newStepName], state.Data[newStepRegexp])
+		},
+	}
+}
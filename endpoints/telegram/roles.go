@@ -0,0 +1,224 @@
+package telegram
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/render"
+)
+
+// role is a set of permissions required by the command
+type role int
+
+const (
+	// roleSubscriber is anyone who can see the chat
+	roleSubscriber role = iota
+	// roleChatAdmin can change subscriptions of the chat, everyone is admin of the private chat with the bot
+	roleChatAdmin
+	// roleFeedManager can create, change and delete feeds and filters
+	roleFeedManager
+	// roleAdmin has all permissions and manages roles of other users
+	roleAdmin
+)
+
+var roleNames = map[role]string{
+	roleSubscriber:  "subscriber",
+	roleChatAdmin:   "chat_admin",
+	roleFeedManager: "feed_manager",
+	roleAdmin:       "admin",
+}
+
+func (r role) String() string {
+	return roleNames[r]
+}
+
+// parseRole parses the role that can be granted with /admin command
+func parseRole(s string) (role, error) {
+	switch s {
+	case roleFeedManager.String():
+		return roleFeedManager, nil
+	case roleAdmin.String():
+		return roleAdmin, nil
+	}
+	return roleSubscriber, fmt.Errorf("role must be one of %s, %s", roleFeedManager, roleAdmin)
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// configRole returns the role given to the user in config, config roles can't be changed with /admin command
+func configRole(from *telego.User) role {
+	switch {
+	case containsID(configs.Config.Admins, from.ID):
+		return roleAdmin
+	case configs.Config.AdminUsername != "" && from.Username == configs.Config.AdminUsername:
+		return roleAdmin
+	case containsID(configs.Config.FeedManagers, from.ID):
+		return roleFeedManager
+	}
+	return roleSubscriber
+}
+
+// grantedRole returns the role given to the user in config or with /admin command, chat admins are not considered
+func (e *TelegramEndpoint) grantedRole(from *telego.User) role {
+	if from == nil {
+		return roleSubscriber
+	}
+	return max(configRole(from), e.roles[from.ID])
+}
+
+// hasRole returns true if user has permissions of the role in the chat. Admins have all permissions, feed managers
+// change subscriptions only of the chats they are admins of.
+func (e *TelegramEndpoint) hasRole(chat telego.Chat, from *telego.User, required role) bool {
+	granted := e.grantedRole(from)
+	switch {
+	case required == roleSubscriber || granted == roleAdmin:
+		return true
+	case required == roleChatAdmin:
+		return e.isChatAdmin(chat, from)
+	}
+	return granted >= required
+}
+
+// messageHasRole returns true if author of the message has permissions of the role in the chat. Messages sent on
+// behalf of the chat itself are channel posts or come from anonymous group admins, both are chat admins.
+func (e *TelegramEndpoint) messageHasRole(message *telego.Message, required role) bool {
+	if message.SenderChat != nil && message.SenderChat.ID == message.Chat.ID {
+		return required <= roleChatAdmin
+	}
+	return e.hasRole(message.Chat, message.From, required)
+}
+
+// isChatAdmin returns true if user is allowed to change subscriptions of the chat
+func (e *TelegramEndpoint) isChatAdmin(chat telego.Chat, from *telego.User) bool {
+	if from == nil {
+		return false
+	}
+	if chat.Type == telego.ChatTypePrivate {
+		return true
+	}
+
+	logger := e.logger.With(zap.String("handler", "accessChecker"))
+	admins, ok := e.chatAdminIDs(logger, chat.ID)
+	return ok && admins[from.ID]
+}
+
+// loadRoles reads roles granted with /admin command
+func (e *TelegramEndpoint) loadRoles() error {
+	roles, err := e.db.GetRoles()
+	if err != nil {
+		return err
+	}
+
+	e.roles = make(map[int64]role, len(roles))
+	for _, r := range roles {
+		granted, err := parseRole(r.Role)
+		if err != nil {
+			e.logger.Warn("unknown role stored in database",
+				zap.Int64("user_id", r.UserID),
+				zap.String("role", r.Role),
+			)
+			continue
+		}
+		e.roles[r.UserID] = granted
+	}
+	return nil
+}
+
+func (e *TelegramEndpoint) handlerAdmin(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "admin"))
+	action := args.get("action")
+	if action == "list" {
+		return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, formatRoles(e.roles))
+	}
+
+	userID, err := strconv.ParseInt(args.get("user_id"), 10, 64)
+	if err != nil || userID <= 0 {
+		return e.usageError("/admin", "user_id must be a numeric Telegram user id")
+	}
+	if configRole(&telego.User{ID: userID}) != roleSubscriber {
+		return errors.New("role of this user is set in config and can't be changed")
+	}
+
+	var message string
+	switch action {
+	case "grant":
+		granted, err := parseRole(args.get("role"))
+		if err != nil {
+			return e.usageError("/admin", err.Error())
+		}
+		err = e.db.SetRole(&db.Role{
+			UserID:  userID,
+			Role:    granted.String(),
+			AddedBy: update.Message.From.ID,
+			AddedAt: time.Now(),
+		})
+		if err != nil {
+			logger.Error("error granting role",
+				zap.Int64("user_id", userID),
+				zap.Error(err),
+			)
+			return errors.New("error occurred while trying to grant role")
+		}
+		e.roles[userID] = granted
+		message = fmt.Sprintf("user %v is %s now", userID, granted)
+	case "revoke":
+		err = e.db.RemoveRole(userID)
+		if errors.Is(err, db.ErrNotFound) {
+			return errors.New("user doesn't have any role")
+		}
+		if err != nil {
+			logger.Error("error revoking role",
+				zap.Int64("user_id", userID),
+				zap.Error(err),
+			)
+			return errors.New("error occurred while trying to revoke role")
+		}
+		delete(e.roles, userID)
+		message = fmt.Sprintf("role of user %v is revoked", userID)
+	}
+
+	// Private chat with the user has the same id
+	e.registerRoleCommands(userID, e.roles[userID])
+	return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, render.Escape(render.FormatMarkdownV2, message))
+}
+
+func formatRoles(roles map[int64]role) string {
+	var lines []string
+	for _, id := range configs.Config.Admins {
+		lines = append(lines, fmt.Sprintf("%v %s (config)", id, roleAdmin))
+	}
+	if configs.Config.AdminUsername != "" {
+		lines = append(lines, fmt.Sprintf("@%s %s (config, deprecated)", configs.Config.AdminUsername, roleAdmin))
+	}
+	for _, id := range configs.Config.FeedManagers {
+		lines = append(lines, fmt.Sprintf("%v %s (config)", id, roleFeedManager))
+	}
+
+	ids := make([]int64, 0, len(roles))
+	for id := range roles {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	for _, id := range ids {
+		lines = append(lines, fmt.Sprintf("%v %s", id, roles[id]))
+	}
+	return render.Escape(render.FormatMarkdownV2, "Roles:\n"+strings.Join(lines, "\n"))
+}
@@ -0,0 +1,53 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/mymmrac/telego"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/configs"
+)
+
+func TestHasRole(t *testing.T) {
+	configs.Config.Admins = []int64{1}
+	configs.Config.FeedManagers = []int64{2}
+	defer func() {
+		configs.Config.Admins = nil
+		configs.Config.FeedManagers = nil
+	}()
+
+	e := &TelegramEndpoint{logger: zap.NewNop(), roles: map[int64]role{3: roleFeedManager, 4: roleAdmin}}
+	private := telego.Chat{ID: 10, Type: telego.ChatTypePrivate}
+
+	tests := []struct {
+		user     int64
+		required role
+		want     bool
+	}{
+		{user: 1, required: roleAdmin, want: true},
+		{user: 2, required: roleFeedManager, want: true},
+		{user: 2, required: roleAdmin, want: false},
+		{user: 3, required: roleFeedManager, want: true},
+		{user: 4, required: roleAdmin, want: true},
+		{user: 5, required: roleFeedManager, want: false},
+		{user: 5, required: roleChatAdmin, want: true},
+		{user: 5, required: roleSubscriber, want: true},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, e.hasRole(private, &telego.User{ID: tt.user}, tt.required), "user %v, role %s", tt.user, tt.required)
+	}
+
+	// Anonymous group admins and channel posts are sent on behalf of the chat
+	group := telego.Chat{ID: -10, Type: telego.ChatTypeSupergroup}
+	anonymous := &telego.Message{Chat: group, SenderChat: &group, From: &telego.User{ID: 1087968824, Username: "GroupAnonymousBot"}}
+	require.True(t, e.messageHasRole(anonymous, roleChatAdmin))
+	require.False(t, e.messageHasRole(anonymous, roleFeedManager))
+
+	_, err := parseRole("chat_admin")
+	require.Error(t, err)
+	r, err := parseRole("feed_manager")
+	require.NoError(t, err)
+	require.Equal(t, roleFeedManager, r)
+}
@@ -0,0 +1,71 @@
+package telegram
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// isChatReference returns true if argument refers to another chat, either by @username or by id. Ids of groups and
+// channels are negative, so they can't be confused with repo names.
+func isChatReference(value string) bool {
+	if strings.HasPrefix(value, "@") {
+		return len(value) > 1
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	return err == nil && id < 0
+}
+
+func chatReference(value string) telego.ChatID {
+	if id, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return tu.ID(id)
+	}
+	return tu.Username(value)
+}
+
+func isAdminMember(member telego.ChatMember) bool {
+	status := member.MemberStatus()
+	return status == telego.MemberStatusCreator || status == telego.MemberStatusAdministrator
+}
+
+// targetChat returns the chat whose subscriptions are managed by the command: the one command was sent to, or the
+// chat given as an argument. Other chats can be managed only from a private chat with the bot, by their admins and
+// only if the bot is admin there as well. Notifications to other chats are sent to their general topic.
+func (e *TelegramEndpoint) targetChat(logger *zap.Logger, args *commandArgs, update *telego.Update) (int64, int, error) {
+	ref := args.get("chat")
+	if ref == "" {
+		return update.Message.Chat.ID, messageThread(update.Message), nil
+	}
+	if update.Message.Chat.Type != telego.ChatTypePrivate || update.Message.From == nil {
+		return 0, 0, errors.New("other chats can be managed only from a private chat with the bot")
+	}
+
+	chatID := chatReference(ref)
+	chat, err := e.api.GetChat(&telego.GetChatParams{ChatID: chatID})
+	if err != nil {
+		logger.Warn("failed to get chat",
+			zap.String("chat", ref),
+			zap.Error(err),
+		)
+		return 0, 0, errors.New("chat " + ref + " not found, bot must be added to it as admin")
+	}
+
+	member, err := e.api.GetChatMember(&telego.GetChatMemberParams{ChatID: chatID, UserID: update.Message.From.ID})
+	if err != nil || !isAdminMember(member) {
+		return 0, 0, errors.New("only admins of " + ref + " can manage its subscriptions")
+	}
+
+	bot, err := e.api.GetChatMember(&telego.GetChatMemberParams{ChatID: chatID, UserID: e.selfID})
+	if err != nil || !isAdminMember(bot) {
+		return 0, 0, errors.New("bot must be admin of " + ref + " to send notifications there")
+	}
+	if admin, ok := bot.(*telego.ChatMemberAdministrator); ok && chat.Type == telego.ChatTypeChannel && !admin.CanPostMessages {
+		return 0, 0, errors.New("bot must be allowed to post messages in " + ref)
+	}
+
+	return chat.ID, 0, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -18,38 +19,39 @@ import (
 	"github.com/Civil/github2telegram/db"
 	"github.com/Civil/github2telegram/endpoints"
 	"github.com/Civil/github2telegram/feeds"
+	"github.com/Civil/github2telegram/render"
+	"github.com/Civil/github2telegram/semver"
 	"github.com/Civil/github2telegram/types"
 )
 
 const (
 	TelegramEndpointName = "telegram"
-)
-
-type user struct {
-	id       int64
-	username string
-}
-
-type handler func(tokens []string, update *telego.Update) error
 
-type handlerWithDescription struct {
-	f           handler
-	description string
-	hidden      bool
-}
+	// maxBackfill is the maximum number of recent releases sent on subscribe
+	maxBackfill = 10
+)
 
 var errUnauthorized = errors.New("unauthorized action")
 
 type TelegramEndpoint struct {
 	api    *telego.Bot
-	admins map[int64][]user
+	admins *adminCache
 	db     db.Database
 
-	logger   *zap.Logger
-	commands map[string]handlerWithDescription
+	logger    *zap.Logger
+	commands  map[string]*command
+	callbacks map[string]callbackHandler
+	// multi-step commands, by command name
+	conversations map[string]*conversation
+	// roles granted with /admin command, by user id
+	roles map[int64]role
+	// adminMenus are private chats with users that already have commands of their role in the menu
+	adminMenus map[int64]bool
 
 	exitChan    <-chan struct{}
 	resendQueue chan *types.NotificationMessage
+	// deletedTopics are forum topics that were deleted while the bot was sending to them
+	deletedTopics *topics
 
 	tgLogger *tgLogger
 
@@ -59,6 +61,10 @@ type TelegramEndpoint struct {
 	listen      string
 
 	selfUser string
+	selfID   int64
+
+	// format of the notifications, command responses are always in MarkdownV2
+	format render.Format
 }
 
 func WithWebhookURL(url string) *endpoints.ConfigParams {
@@ -82,6 +88,14 @@ func WithWebhookPath(path string) *endpoints.ConfigParams {
 	}
 }
 
+// WithParseMode sets markup of the notifications: markdownv2 (default), html or plain
+func WithParseMode(mode string) *endpoints.ConfigParams {
+	return &endpoints.ConfigParams{
+		Name:  "parse_mode",
+		Value: mode,
+	}
+}
+
 func InitializeTelegramEndpoint(token string, exitChan <-chan struct{}, database db.Database, configParams ...*endpoints.ConfigParams) (*TelegramEndpoint, error) {
 	logger := zapwriter.Logger(TelegramEndpointName)
 	tgEndpointLogger := newTgLogger(logger, []string{token, "<TOKEN REDACTED>"})
@@ -91,13 +105,19 @@ func InitializeTelegramEndpoint(token string, exitChan <-chan struct{}, database
 	}
 
 	e := &TelegramEndpoint{
-		api:         bot,
-		admins:      make(map[int64][]user),
-		logger:      logger,
-		exitChan:    exitChan,
-		resendQueue: make(chan *types.NotificationMessage, 1000),
-		db:          database,
-		tgLogger:    tgEndpointLogger,
+		api:           bot,
+		admins:        newAdminCache(adminCacheTTL),
+		deletedTopics: newTopics(),
+		logger:        logger,
+		exitChan:      exitChan,
+		resendQueue:   make(chan *types.NotificationMessage, 1000),
+		db:            database,
+		tgLogger:      tgEndpointLogger,
+	}
+
+	err = e.loadRoles()
+	if err != nil {
+		return nil, err
 	}
 
 	for _, param := range configParams {
@@ -115,6 +135,11 @@ func InitializeTelegramEndpoint(token string, exitChan <-chan struct{}, database
 			if len(param.Value) > 0 {
 				e.webhookPath = param.Value
 			}
+		case "parse_mode":
+			e.format, err = render.ParseFormat(param.Value)
+			if err != nil {
+				return nil, err
+			}
 		default:
 			return nil, fmt.Errorf("unknown config param %s", param.Name)
 		}
@@ -122,7 +147,8 @@ func InitializeTelegramEndpoint(token string, exitChan <-chan struct{}, database
 
 	if e.useWebHook {
 		err = bot.SetWebhook(&telego.SetWebhookParams{
-			URL: e.webhookURL + "/" + e.webhookPath + bot.Token(),
+			URL:            e.webhookURL + "/" + e.webhookPath + bot.Token(),
+			AllowedUpdates: allowedUpdates,
 		})
 		if err != nil {
 			return nil, err
@@ -148,57 +174,288 @@ func InitializeTelegramEndpoint(token string, exitChan <-chan struct{}, database
 	}
 
 	e.selfUser = botUser.Username
+	e.selfID = botUser.ID
 
 	logger.Debug("bot account",
 		zap.String("username", botUser.Username),
 	)
 
-	e.commands = map[string]handlerWithDescription{
-		"/new": {
-			f: e.handlerNew,
-			description: "`/new repo filter\\_name filter_regexp` \\-\\- creates new available subscription" + `
-
-Example:
+	commands := []*command{
+		{
+			name:        "/new",
+			role:        roleFeedManager,
+			summary:     "create a feed for a repo",
+			localized:   map[string]string{"ru": "создать фид для репозитория"},
+			f:           e.handlerNew,
+			args:        []argument{{name: "repo"}, {name: "filter_name"}, {name: "filter_regexp"}},
+			interactive: true,
+			description: "creates new available subscription, without arguments asks for them one by one",
+			details: `Example:
   ` + "`/new lomik/go\\-carbon all ^V`" + `
 
   This will create repo named 'lomik/go\-carbon', with filter called 'all' and regexp that will grab all tags that starts from capital 'V'`,
 		},
-		"/subscribe": {
-			f: e.handlerSubscribe,
-			description: "`/subscribe repo filter\\_name` \\-\\- subscribe current channel to specific repo and filter" + `
+		{
+			name:        "/edit",
+			role:        roleFeedManager,
+			summary:     "change regexp of a filter",
+			localized:   map[string]string{"ru": "изменить регулярное выражение фильтра"},
+			f:           e.handlerEdit,
+			args:        []argument{{name: "repo"}, {name: "filter_name"}, {name: "filter_regexp"}},
+			description: "change regexp of existing filter, subscribed chats are notified",
+			details: `Example:
+  ` + "`/edit lomik/go\\-carbon all ^v[0-9]`",
+		},
+		{
+			name:      "/delete",
+			role:      roleFeedManager,
+			summary:   "delete a filter",
+			localized: map[string]string{"ru": "удалить фильтр"},
+			f:         e.handlerDelete,
+			args:      []argument{{name: "repo"}, {name: "filter_name"}},
+			options: []option{
+				{name: "migrate", usage: "filter_name", description: "move subscriptions to another filter of the same repo instead of removing them"},
+			},
+			description: "delete filter after confirmation, its subscriptions are removed and subscribed chats are notified",
+			details: `Example:
+  ` + "`/delete lomik/go\\-carbon all migrate=stable`",
+		},
+		{
+			name:      "/test",
+			role:      roleChatAdmin,
+			summary:   "check which releases a filter matches",
+			localized: map[string]string{"ru": "проверить, какие релизы подходят под фильтр"},
+			f:         e.handlerTest,
+			args:      []argument{{name: "repo"}, {name: "filter_regexp", optional: true}},
+			options: []option{
+				{name: "filter", usage: "filter_name", description: "use rules, version filter and template of existing filter, its regexp is used if filter\\_regexp is omitted"},
+			},
+			description: "check which of the recent releases of the repo would be announced, nothing is sent to subscribers",
+			details: `Examples:
+  ` + "`/test lomik/go\\-carbon ^v[0-9]`" + `
+  ` + "`/test lomik/go\\-carbon filter=stable`",
+		},
+		{
+			name:        "/latest",
+			role:        roleChatAdmin,
+			summary:     "show the latest release of a repo",
+			localized:   map[string]string{"ru": "показать последний релиз репозитория"},
+			f:           e.handlerLatest,
+			args:        []argument{{name: "repo"}, {name: "filter_name", optional: true}},
+			description: "show the most recent release of the repo matched by the filter the same way it's announced, subscriptions aren't affected",
+			details: `Example:
+  ` + "`/latest lomik/go\\-carbon stable`",
+		},
+		{
+			name:        "/cancel",
+			role:        roleSubscriber,
+			summary:     "cancel the current question",
+			localized:   map[string]string{"ru": "отменить текущий вопрос"},
+			f:           e.handlerCancel,
+			description: "cancel command that is waiting for your answer",
+		},
+		{
+			name:      "/subscribe",
+			role:      roleChatAdmin,
+			summary:   "subscribe this chat to a repo",
+			localized: map[string]string{"ru": "подписать чат на репозиторий"},
+			f:         e.handlerSubscribe,
+			args:      []argument{{name: "chat", optional: true, match: isChatReference}, {name: "repo"}, {name: "filter_name"}},
+			options: []option{
+				{name: "last", usage: "N", description: fmt.Sprintf("send N most recent matching releases right away, up to %v", maxBackfill)},
+			},
+			description: "subscribe current channel to specific repo and filter",
+			details: `Channel or group where you and the bot are admins can be subscribed from a private chat with the bot by its @username or id\.
+
+Examples:
+  ` + "`/subscribe lomik/go\\-carbon all last=3`" + `
+  ` + "`/subscribe @releases lomik/go\\-carbon all`",
+		},
+		{
+			name:        "/unsubscribe",
+			role:        roleChatAdmin,
+			summary:     "unsubscribe this chat from a repo",
+			localized:   map[string]string{"ru": "отписать чат от репозитория"},
+			f:           e.handlerUnsubscribe,
+			args:        []argument{{name: "chat", optional: true, match: isChatReference}, {name: "repo"}, {name: "filter_name"}},
+			description: "unsubscribe current channel to specific repo and filter",
+			details: `Examples:
+  ` + "`/unsubscribe lomik/go\\-carbon all`" + `
+  ` + "`/unsubscribe @releases lomik/go\\-carbon all`",
+		},
+		{
+			name:      "/semver",
+			role:      roleFeedManager,
+			summary:   "set semantic version filter",
+			localized: map[string]string{"ru": "настроить фильтр по семантическим версиям"},
+			f:         e.handlerSemver,
+			args:      []argument{{name: "repo"}, {name: "filter_name"}, {name: "action", values: "off", optional: true}},
+			options: []option{
+				{name: "constraint", usage: `">=2.0.0 <3"`, description: "notify only about versions that satisfy constraint"},
+				{name: "bump", usage: "major|minor|patch", description: "notify only if version changed at least that much since last notification"},
+				{name: "prereleases", usage: "include|exclude", description: "skip versions like 1\\.0\\.0\\-rc1 if excluded"},
+				{name: "greater", usage: "true|false", description: "skip versions that are not greater than last notified one \\(e\\.x\\. backports\\)"},
+				{name: "prefix", usage: "component/v", description: "tag prefix, detected automatically if not set"},
+			},
+			description: "set semantic version filter for existing filter, without options shows current one, " + "`off`" + " removes it",
+			details: `Example:
+  ` + "`/semver lomik/go\\-carbon all constraint=\">=0.15\" prereleases=exclude greater=true`",
+		},
+		{
+			name:      "/rules",
+			role:      roleFeedManager,
+			summary:   "manage include and exclude rules",
+			localized: map[string]string{"ru": "управлять правилами включения и исключения"},
+			f:         e.handlerRules,
+			args: []argument{
+				{name: "repo"},
+				{name: "filter_name"},
+				{name: "action", values: "include|exclude|delete|clear", optional: true},
+				{name: "args", rest: true},
+			},
+			description: "manage ordered include and exclude patterns of existing filter, without arguments lists current rules",
+			details: `Rules are applied to releases matched by filter's regexp, the last matching rule decides if release is announced\. Patterns are matched against release title, unless ` + "`body`" + ` is specified\. Pattern is everything after the action, quotes are not needed\.
 
 Example:
-  ` + "`/subscribe lomik/go-carbon all`" + `
-`,
+  ` + "`/rules lomik/go\\-carbon all exclude -rc`" + `
+  ` + "`/rules lomik/go\\-carbon all exclude -nightly`" + `
+  ` + "`/rules lomik/go\\-carbon all include body (?i)security fix`" + `
+  ` + "`/rules lomik/go\\-carbon all delete 2`",
 		},
-		"/unsubscribe": {
-			f: e.handlerUnsubscribe,
-			description: "`/unsubscribe repo filter\\_name`  \\-\\- unsubscribe current channel to specific repo and filter" + `
+		{
+			name:      "/channels",
+			role:      roleChatAdmin,
+			summary:   "choose release channels",
+			localized: map[string]string{"ru": "выбрать каналы релизов"},
+			f:         e.handlerChannels,
+			args:      []argument{{name: "repo"}, {name: "filter_name"}, {name: "channels", optional: true}},
+			options: []option{
+				{name: "silent", usage: "channels", description: "channels that are delivered without sound"},
+			},
+			description: "choose which release channels current chat receives for the subscription and which of them arrive silently, without arguments shows current settings",
+			details: `Channels: ` + "`" + types.JoinChannels(types.AllChannels) + "`" + `, or ` + "`all`" + `
 
 Example:
-  ` + "`/unsubscribe lomik/go\\-carbon all`" + `
-`,
+  ` + "`/channels lomik/go\\-carbon all stable,prerelease silent=prerelease`",
 		},
-		"/list": {
-			f:           e.handlerList,
-			description: "`/list` \\-\\- lists all available repos",
+		{
+			name:      "/settings",
+			role:      roleChatAdmin,
+			summary:   "change notification settings",
+			localized: map[string]string{"ru": "изменить настройки уведомлений"},
+			f:         e.handlerSettings,
+			args:      []argument{{name: "repo"}, {name: "filter_name"}},
+			options: []option{
+				{name: "edits", usage: "on|off", description: "notify when release notes are edited"},
+				{name: "length", usage: "N|max|default", description: "maximum length of release notes in characters"},
+				{name: "notes", usage: "truncate|split|document", description: "what to do with notes that are too long: cut them, send complete notes in several messages or attach them as a markdown file"},
+				{name: "mute", usage: "N|off", description: "don't send notifications for N days"},
+			},
+			description: "change settings of current chat's subscription, without options shows current settings",
+			details: `Example:
+  ` + "`/settings lomik/go\\-carbon all edits=off length=1000 notes=document`",
 		},
-
-		"/forceProcess": {
-			hidden: true,
-			f:      e.handlerForceProcess,
-			description: "`/forceProcess repo` \\-\\- force process repository \\(can be only executed by account specified in config, for debug purpose only\\)" + `
+		{
+			name:      "/template",
+			role:      roleChatAdmin,
+			summary:   "change notification template",
+			localized: map[string]string{"ru": "изменить шаблон уведомлений"},
+			f:         e.handlerTemplate,
+			args: []argument{
+				{name: "repo"},
+				{name: "filter_name"},
+				{name: "action", values: "preview|chat|feed", optional: true},
+				{name: "template", rest: true},
+			},
+			description: "change how notifications look like, for current chat or for everyone subscribed to the filter, without arguments shows current templates, " + "`reset`" + " restores the default one",
+			details: `Templates use Go text/template syntax, available fields: ` + "`.Repo`, `.Tag`, `.Title`, `.Link`, `.URL`, `.Notes`, `.Diff`, `.Channel`, `.Assets`, `.PreviousVersion`, `.Compare`, `.Type`, `.Action`" + `\. When too many releases are published at once, they are announced as a single message with ` + "`.Type`" + ` set to ` + "`summary`" + ` and ` + "`.Releases`" + ` listing them\. Values are already escaped and notes are formatted, use ` + "`.URL`" + ` as link target\. Unescaped values are available as ` + "`.Raw.Title`" + ` etc\. Template is everything after the action, quotes are not needed\.
 
 Example:
-  ` + "`/new lomik/go\\-carbon all ^V`" + `
-
-  This will create repo named 'lomik/go\-carbon', with filter called 'all' and regexp that will grab all tags that starts from capital 'V'`,
+  ` + "`/template lomik/go\\-carbon all chat *{{.Repo}}* {{.Tag}} [changelog]({{.URL}})`" + `
+  ` + "`/template lomik/go\\-carbon all preview`",
+		},
+		{
+			name:        "/mysubs",
+			role:        roleChatAdmin,
+			summary:     "list subscriptions of this chat",
+			localized:   map[string]string{"ru": "подписки этого чата"},
+			f:           e.handlerMySubscriptions,
+			args:        []argument{{name: "page", optional: true}},
+			description: "lists subscriptions of current chat with their channels and last announced release",
+		},
+		{
+			name:        "/list",
+			role:        roleSubscriber,
+			summary:     "list available repos",
+			localized:   map[string]string{"ru": "список доступных репозиториев"},
+			f:           e.handlerList,
+			description: "lists all available repos",
+		},
+		{
+			name:        "/forceProcess",
+			role:        roleAdmin,
+			summary:     "check a repo for new releases now",
+			localized:   map[string]string{"ru": "проверить репозиторий на новые релизы сейчас"},
+			f:           e.handlerForceProcess,
+			args:        []argument{{name: "repo"}},
+			description: "force process repository \\(for debug purpose only\\)",
 		},
-		"/help": {
+		{
+			name:      "/admin",
+			role:      roleAdmin,
+			summary:   "manage roles of bot users",
+			localized: map[string]string{"ru": "управлять ролями пользователей бота"},
+			f:         e.handlerAdmin,
+			args: []argument{
+				{name: "action", values: "list|grant|revoke"},
+				{name: "user_id", optional: true},
+				{name: "role", values: "feed_manager|admin", optional: true},
+			},
+			description: "list, grant or revoke roles, roles set in config can't be changed",
+			details: `Feed managers create and change feeds, admins can do everything\. Chat admins manage subscriptions of their chats without any role\. User id is shown by bots like @userinfobot\.
+
+Examples:
+  ` + "`/admin grant 123456789 feed_manager`" + `
+  ` + "`/admin revoke 123456789`",
+		},
+		{
+			name:        "/chats",
+			role:        roleAdmin,
+			summary:     "list chats the bot was added to",
+			localized:   map[string]string{"ru": "чаты, в которые добавлен бот"},
+			f:           e.handlerChats,
+			args:        []argument{{name: "filter", values: "all", optional: true}},
+			description: "lists chats the bot is a member of with their subscriptions, " + "`all`" + " includes chats the bot was removed from",
+		},
+		{
+			name:        "/help",
+			role:        roleSubscriber,
+			summary:     "show help",
+			localized:   map[string]string{"ru": "показать справку"},
 			f:           e.handlerHelp,
-			description: "`/help` \\-\\- display current help",
+			description: "display current help",
 		},
 	}
+	e.commands = make(map[string]*command, len(commands))
+	for _, c := range commands {
+		// Commands in Telegram's menu are lowercase, so they're matched case insensitively
+		e.commands[strings.ToLower(c.name)] = c
+	}
+	e.adminMenus = make(map[int64]bool)
+	e.registerCommands()
+
+	e.callbacks = map[string]callbackHandler{
+		callbackMute:         e.callbackMute,
+		callbackUnsubscribe:  e.callbackUnsubscribe,
+		callbackConversation: e.callbackConversationAnswer,
+
+		callbackSubscriptionsPage:        e.callbackSubscriptionsPage,
+		callbackSubscriptionsUnsubscribe: e.callbackSubscriptionsUnsubscribe,
+	}
+	e.conversations = map[string]*conversation{
+		"/new":    e.newConversation(),
+		"/delete": e.deleteConversation(),
+	}
 
 	messages, err := e.db.GetMessagesFromResentQueue()
 	if err != nil {
@@ -230,7 +487,7 @@ func (e *TelegramEndpoint) processResendQueue() {
 			}
 			return
 		case msg := <-e.resendQueue:
-			err := e.sendMessage(msg.ChatID, 0, msg.Message)
+			err := e.sendNotification(msg)
 			if err != nil {
 				if !e.checkUnrecoverableSendError(err) {
 					// We'll just forget that message if error here is unrecoverable
@@ -244,23 +501,49 @@ func (e *TelegramEndpoint) processResendQueue() {
 	}
 }
 
-func (e *TelegramEndpoint) Send(url, filter, message string) error {
+func (e *TelegramEndpoint) Send(update *types.Update) error {
 	logger := e.logger.With(zap.String("handler", "send"))
-	ids, err := e.db.GetEndpointInfo(TelegramEndpointName, url, filter)
+	subscriptions, err := e.db.GetSubscriptions(TelegramEndpointName, update.Repo, update.Filter)
 	logger.Info("endpoint info",
 		zap.Error(err),
-		zap.Int64s("ids", ids),
+		zap.Int("subscriptions", len(subscriptions)),
 	)
 	if err != nil {
 		return err
 	}
 
-	for _, id := range ids {
-		err = e.sendMessage(id, 0, message)
+	for _, sub := range subscriptions {
+		id := sub.ChatID
+		if update.Type == types.DescriptionChange && !sub.NotifyDescriptionChanges {
+			logger.Debug("subscription doesn't want description changes",
+				zap.Int64("ChatID", id),
+			)
+			continue
+		}
+
+		if sub.MutedUntil.After(time.Now()) {
+			logger.Debug("subscription is muted",
+				zap.Int64("ChatID", id),
+				zap.Time("muted_until", sub.MutedUntil),
+			)
+			continue
+		}
+
+		deliver, silent := sub.Accepts(update.Channels)
+		if !deliver {
+			logger.Debug("subscription doesn't accept release channel",
+				zap.Int64("ChatID", id),
+				zap.Any("channels", update.Channels),
+				zap.Any("subscription_channels", sub.Channels),
+			)
+			continue
+		}
+
+		err = e.sendUpdate(logger, update, sub, silent)
 		if err != nil {
 			// Check if we actually need to forget about that chat
 			if !e.checkUnrecoverableSendError(err) {
-				err2 := e.unsubscribe(logger, id, url, filter)
+				err2 := e.unsubscribe(logger, id, update.Repo, update.Filter)
 				if err2 != nil {
 					logger.Warn("failed to unsubscribe",
 						zap.Error(err2),
@@ -268,25 +551,140 @@ func (e *TelegramEndpoint) Send(url, filter, message string) error {
 				} else {
 					logger.Warn("unsubscribed from chat",
 						zap.Int64("ChatID", id),
-						zap.String("url", url),
-						zap.String("filter", filter),
+						zap.String("url", update.Repo),
+						zap.String("filter", update.Filter),
 						zap.String("reason", err.Error()),
 					)
 				}
-				continue
-			}
-			// Check if we need to adjust our chat id
-			id = e.checkAndChangeChatID(logger, id, err.Error())
-			e.resendQueue <- &types.NotificationMessage{
-				ChatID:  id,
-				Message: message,
 			}
+			continue
 		}
 	}
 
 	return nil
 }
 
+// sendUpdate renders the update using subscription's settings and sends it to the subscribed chat. Failure to send
+// the release notes document is only logged.
+func (e *TelegramEndpoint) sendUpdate(logger *zap.Logger, update *types.Update, sub *db.Subscription, silent bool) error {
+	notification := e.renderUpdate(logger, update, sub)
+	err := e.sendMessages(logger, sub, notification, notificationButtons(update, sub), silent)
+	if err != nil {
+		return err
+	}
+
+	if notification.Document != "" {
+		err = e.sendDocument(sub.ChatID, sub.ThreadID, notification.DocumentName, notification.Document, silent)
+		if err != nil {
+			logger.Warn("failed to send release notes document",
+				zap.Int64("ChatID", sub.ChatID),
+				zap.String("url", update.Repo),
+				zap.Error(err),
+			)
+		}
+	}
+	return nil
+}
+
+// sendMessages sends all messages of the notification in order, buttons are attached to the last one. If sending
+// fails with recoverable error, the message and all that follow it are put into resend queue.
+func (e *TelegramEndpoint) sendMessages(logger *zap.Logger, sub *db.Subscription, notification *render.Notification, buttons [][]types.Button, silent bool) error {
+	last := len(notification.Messages) - 1
+	for i, m := range notification.Messages {
+		msg := &types.NotificationMessage{
+			ChatID:   sub.ChatID,
+			ThreadID: sub.ThreadID,
+			Message:  m.Text,
+			Fallback: m.Fallback,
+			Silent:   silent,
+		}
+		if i == last {
+			msg.Buttons = buttons
+		}
+		err := e.sendNotification(msg)
+		if err == nil {
+			continue
+		}
+		if !e.checkUnrecoverableSendError(err) {
+			return err
+		}
+
+		// Check if we need to adjust our chat id
+		chatID := e.checkAndChangeChatID(logger, sub.ChatID, err.Error())
+		for j, rest := range notification.Messages[i:] {
+			msg = &types.NotificationMessage{
+				ChatID:   chatID,
+				ThreadID: msg.ThreadID,
+				Message:  rest.Text,
+				Fallback: rest.Fallback,
+				Silent:   silent,
+			}
+			if i+j == last {
+				msg.Buttons = buttons
+			}
+			e.resendQueue <- msg
+		}
+		return err
+	}
+	return nil
+}
+
+// messageTemplate returns template that should be used for the subscription. Subscription's own template takes
+// precedence over the feed's one, legacy patterns of the feeds are ignored.
+func messageTemplate(subscriptionTemplate, feedPattern string) string {
+	if subscriptionTemplate != "" {
+		return subscriptionTemplate
+	}
+	if render.IsTemplate(feedPattern) {
+		return feedPattern
+	}
+	return ""
+}
+
+// renderOptions returns rendering options requested by the subscription
+func (e *TelegramEndpoint) renderOptions(logger *zap.Logger, sub *db.Subscription) render.Options {
+	mode, err := render.ParseNotesMode(sub.NotesMode)
+	if err != nil {
+		logger.Error("invalid notes mode stored for subscription",
+			zap.Int64("chat_id", sub.ChatID),
+			zap.String("notes_mode", sub.NotesMode),
+			zap.Error(err),
+		)
+	}
+	return render.Options{
+		Format:     e.format,
+		NotesLimit: sub.NotesLength,
+		NotesMode:  mode,
+	}
+}
+
+// renderUpdate renders notification for the subscription, falling back to the default template if custom one can't be
+// rendered
+func (e *TelegramEndpoint) renderUpdate(logger *zap.Logger, update *types.Update, sub *db.Subscription) *render.Notification {
+	text := messageTemplate(sub.Template, update.Template)
+	opts := e.renderOptions(logger, sub)
+	notification, err := render.RenderNotification(update, text, opts)
+	if err == nil {
+		return notification
+	}
+
+	logger.Warn("failed to render notification, using default template",
+		zap.String("repo", update.Repo),
+		zap.String("filter", update.Filter),
+		zap.String("template", text),
+		zap.Error(err),
+	)
+	notification, err = render.RenderNotification(update, "", opts)
+	if err != nil {
+		// Default template always produces a message, this should never happen
+		logger.Error("failed to render notification with default template",
+			zap.Error(err),
+		)
+		return &render.Notification{}
+	}
+	return notification
+}
+
 func (e *TelegramEndpoint) checkAndChangeChatID(logger *zap.Logger, id int64, error string) int64 {
 	if strings.Contains(error, "migrate to chat ID:") {
 		re := regexp.MustCompile(`migrate\s+to\s+chat\s+ID:\s([-0-9]+)`)
@@ -330,6 +728,33 @@ func (e *TelegramEndpoint) checkAndChangeChatID(logger *zap.Logger, id int64, er
 }
 
 func (e *TelegramEndpoint) sendMessage(chatID int64, messageID int, message string) error {
+	return e.sendMessageWithButtons(chatID, messageID, message, nil)
+}
+
+// sendThreadMessage sends message to the forum topic, general topic is used if the forum topic was deleted
+func (e *TelegramEndpoint) sendThreadMessage(chatID int64, threadID int, message string) error {
+	threadID = e.deletedTopics.thread(chatID, threadID)
+	msg := tu.Message(
+		tu.ID(chatID),
+		message,
+	).WithParseMode(telego.ModeMarkdownV2).WithMessageThreadID(threadID)
+
+	_, err := e.api.SendMessage(msg)
+	if err != nil && isTopicDeletedError(err) && threadID != 0 {
+		e.topicDeleted(chatID, threadID)
+		msg.MessageThreadID = 0
+		_, err = e.api.SendMessage(msg)
+	}
+	if err != nil {
+		e.logger.Error("failed to send Message",
+			zap.Any("msg", msg),
+			zap.Error(err),
+		)
+	}
+	return err
+}
+
+func (e *TelegramEndpoint) sendMessageWithButtons(chatID int64, messageID int, message string, buttons [][]types.Button) error {
 	msg := tu.Message(
 		tu.ID(chatID),
 		message,
@@ -340,6 +765,9 @@ func (e *TelegramEndpoint) sendMessage(chatID int64, messageID int, message stri
 		}
 		msg = msg.WithReplyParameters(replyParams)
 	}
+	if len(buttons) > 0 {
+		msg = msg.WithReplyMarkup(inlineKeyboard(buttons))
+	}
 
 	_, err := e.api.SendMessage(msg)
 	if err != nil {
@@ -351,11 +779,12 @@ func (e *TelegramEndpoint) sendMessage(chatID int64, messageID int, message stri
 	return err
 }
 
-func (e *TelegramEndpoint) sendRawMessage(chatID int64, messageID int, message string) error {
+// sendForceReply sends the question that opens reply interface for the sender of the replied message
+func (e *TelegramEndpoint) sendForceReply(chatID int64, messageID int, message string) error {
 	msg := tu.Message(
 		tu.ID(chatID),
 		message,
-	)
+	).WithParseMode(telego.ModeMarkdownV2).WithReplyMarkup(&telego.ForceReply{ForceReply: true, Selective: true})
 	if messageID != 0 {
 		replyParams := &telego.ReplyParameters{
 			MessageID: messageID,
@@ -365,7 +794,7 @@ func (e *TelegramEndpoint) sendRawMessage(chatID int64, messageID int, message s
 
 	_, err := e.api.SendMessage(msg)
 	if err != nil {
-		e.logger.Error("failed to send raw Message",
+		e.logger.Error("failed to send Message",
 			zap.Any("msg", msg),
 			zap.Error(err),
 		)
@@ -373,40 +802,125 @@ func (e *TelegramEndpoint) sendRawMessage(chatID int64, messageID int, message s
 	return err
 }
 
-// returns true if user can issue commands
-func (e *TelegramEndpoint) checkAuthorized(update *telego.Update) bool {
-	logger := e.logger.With(zap.String("handler", "accessChecker"))
-	if update.Message.Chat.Type != "private" {
-		chatID := update.Message.Chat.ID
-		admins, ok := e.admins[chatID]
-		if !ok {
-			params := &telego.GetChatAdministratorsParams{}
-			members, err := e.api.GetChatAdministrators(params.WithChatID(tu.ID(chatID)))
-			if err != nil {
-				logger.Error("failed to get chat admins",
-					zap.Error(err),
-				)
-				return false
-			}
-			for _, m := range members {
-				admins = append(admins, user{m.MemberUser().ID, m.MemberUser().Username})
-			}
-			e.admins[chatID] = admins
-		}
+func parseMode(format render.Format) string {
+	switch format {
+	case render.FormatMarkdownV2:
+		return telego.ModeMarkdownV2
+	case render.FormatHTML:
+		return telego.ModeHTML
+	}
+	return ""
+}
+
+// isFormattingError returns true if Telegram rejected the message because of invalid markup
+func isFormattingError(err error) bool {
+	return strings.Contains(err.Error(), "can't parse entities")
+}
+
+// isTopicDeletedError returns true if message was rejected because its forum topic doesn't exist anymore
+func isTopicDeletedError(err error) bool {
+	return strings.Contains(err.Error(), "message thread not found") || strings.Contains(err.Error(), "TOPIC_DELETED")
+}
 
-		logger.Debug("list of admins",
-			zap.Any("admins", admins),
+// topicDeleted moves subscriptions that were sent to the deleted forum topic to the general one and tells the chat
+// about that. Subscriptions loaded before that still have the deleted topic, so it's handled only once.
+func (e *TelegramEndpoint) topicDeleted(chatID int64, threadID int) {
+	if !e.deletedTopics.add(chatID, threadID) {
+		return
+	}
+	logger := e.logger.With(
+		zap.Int64("chat_id", chatID),
+		zap.Int("thread_id", threadID),
+	)
+	logger.Warn("forum topic was deleted, moving its subscriptions to general topic")
+	err := e.db.ResetThread(chatID, threadID)
+	if err != nil {
+		logger.Error("failed to reset forum topic of subscriptions",
+			zap.Error(err),
 		)
+	}
+	_ = e.sendMessage(chatID, 0, "forum topic used for notifications was deleted, they will be sent to the general topic\\. "+
+		"Run /subscribe in another topic to move them there")
+}
 
-		for _, user := range admins {
-			if user.id == update.Message.From.ID {
-				return true
-			}
+// sendNotification sends release notification, silent notifications are delivered without sound. If Telegram
+// rejects formatting of the message, its plain text version is sent instead.
+func (e *TelegramEndpoint) sendNotification(notification *types.NotificationMessage) error {
+	msg := tu.Message(
+		tu.ID(notification.ChatID),
+		notification.Message,
+	).WithParseMode(parseMode(e.format))
+	notification.ThreadID = e.deletedTopics.thread(notification.ChatID, notification.ThreadID)
+	if notification.Silent {
+		msg = msg.WithDisableNotification()
+	}
+	if len(notification.Buttons) > 0 {
+		msg = msg.WithReplyMarkup(inlineKeyboard(notification.Buttons))
+	}
+
+	if notification.ThreadID != 0 {
+		msg = msg.WithMessageThreadID(notification.ThreadID)
+	}
+
+	_, err := e.api.SendMessage(msg)
+	if err != nil && isTopicDeletedError(err) && notification.ThreadID != 0 {
+		e.topicDeleted(notification.ChatID, notification.ThreadID)
+		notification.ThreadID = 0
+		msg.MessageThreadID = 0
+		_, err = e.api.SendMessage(msg)
+	}
+	if err != nil && isFormattingError(err) && notification.Fallback != "" {
+		e.logger.Warn("notification formatting was rejected, sending plain text",
+			zap.Any("msg", msg),
+			zap.Error(err),
+		)
+		msg.Text = notification.Fallback
+		msg.ParseMode = ""
+		_, err = e.api.SendMessage(msg)
+	}
+	if err != nil {
+		e.logger.Error("failed to send notification",
+			zap.Any("msg", msg),
+			zap.Error(err),
+		)
+	}
+	return err
+}
+
+// sendDocument sends text as a file
+func (e *TelegramEndpoint) sendDocument(chatID int64, threadID int, name, content string, silent bool) error {
+	params := tu.Document(
+		tu.ID(chatID),
+		tu.File(tu.NameReader(strings.NewReader(content), name)),
+	).WithMessageThreadID(e.deletedTopics.thread(chatID, threadID))
+	if silent {
+		params = params.WithDisableNotification()
+	}
+
+	_, err := e.api.SendDocument(params)
+	return err
+}
+
+func (e *TelegramEndpoint) sendRawMessage(chatID int64, messageID int, message string) error {
+	msg := tu.Message(
+		tu.ID(chatID),
+		message,
+	)
+	if messageID != 0 {
+		replyParams := &telego.ReplyParameters{
+			MessageID: messageID,
 		}
-		return update.Message.From.Username == configs.Config.AdminUsername
+		msg = msg.WithReplyParameters(replyParams)
 	}
 
-	return true
+	_, err := e.api.SendMessage(msg)
+	if err != nil {
+		e.logger.Error("failed to send raw Message",
+			zap.Any("msg", msg),
+			zap.Error(err),
+		)
+	}
+	return err
 }
 
 func (e *TelegramEndpoint) isRepoNameValid(repo string) error {
@@ -449,21 +963,22 @@ func (e *TelegramEndpoint) isFilterNameValid(filterName string) error {
 	return nil
 }
 
-func (e *TelegramEndpoint) handlerNew(tokens []string, update *telego.Update) error {
-	if !e.checkAuthorized(update) {
-		return errUnauthorized
-	}
-	if len(tokens) < 4 {
-		return errors.New("command require exactly 4 arguments\n\n" + e.commands["/new"].description)
+func (e *TelegramEndpoint) handlerNew(args *commandArgs, update *telego.Update) error {
+	if args.empty() {
+		if update.Message.From == nil {
+			return e.usageError("/new", "guided setup isn't available in channels, specify all arguments")
+		}
+		return e.startConversation("/new", update.Message.Chat.ID, update.Message.From.ID, update.Message.MessageID, nil)
 	}
 
+	repo := args.get("repo")
+	name := args.get("filter_name")
+	filter := args.get("filter_regexp")
 	e.logger.Debug("got repo add request",
-		zap.Strings("tokens", tokens),
+		zap.String("repo", repo),
+		zap.String("filter_name", name),
+		zap.String("filter", filter),
 	)
-
-	repo := tokens[1]
-	name := tokens[2]
-	filter := tokens[3]
 	err := e.isRepoNameValid(repo)
 	if err != nil {
 		return errors.Wrap(err, "invalid repo_name")
@@ -479,43 +994,39 @@ func (e *TelegramEndpoint) handlerNew(tokens []string, update *telego.Update) er
 		return errors.Wrap(err, "invalid regexp")
 	}
 
+	response, err := e.createFeed(repo, name, filter)
+	if err != nil {
+		return err
+	}
+	return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, response)
+}
+
+// createFeed checks that repo exists and starts processing new filter, returned text is sent to the user
+func (e *TelegramEndpoint) createFeed(repo, name, filter string) (string, error) {
 	resp, err := http.Get(fmt.Sprintf("https://github.com/%s/releases.atom", repo))
 	if err != nil {
-		return errors.Wrap(err, "repo is not accessible or doesn't exist")
+		return "", errors.Wrap(err, "repo is not accessible or doesn't exist")
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return errors.New(fmt.Sprintf("repo is not accessible or doesn't exist, http_code: %v", resp.StatusCode))
-	}
-
-	pattern := "https://github.com/%v/releases/%v was tagged"
-
-	tmp := fmt.Sprintf(pattern, repo, "1.0")
-	if strings.Contains(tmp, "%!") {
-		return errors.New("Invalid Message pattern!")
+		return "", errors.New(fmt.Sprintf("repo is not accessible or doesn't exist, http_code: %v", resp.StatusCode))
 	}
 
-	feed, err := feeds.NewFeed(repo, filter, name, pattern, e.db)
+	// Empty message pattern means default template, it can be changed with /template
+	feed, err := feeds.NewFeed(repo, filter, name, "", e.db)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	feeds.UpdateFeeds([]*feeds.Feed{feed})
 
-	return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, fmt.Sprintf("new filter has been created, to subscribe it use `/subscribe %s %s` command", repo, name))
+	return fmt.Sprintf("new filter has been created, to subscribe it use `/subscribe %s %s` command", repo, name), nil
 }
 
-func (e *TelegramEndpoint) handlerForceProcess(tokens []string, update *telego.Update) error {
-	if update.Message.From.Username != configs.Config.AdminUsername {
-		return errUnauthorized
-	}
-	if len(tokens) < 2 {
-		return errors.New("Command require exactly 1 arguments\n\n" + e.commands["/forceProcess"].description)
-	}
-
-	repo := tokens[1]
+func (e *TelegramEndpoint) handlerForceProcess(args *commandArgs, update *telego.Update) error {
+	repo := args.get("repo")
 
 	err := e.isRepoNameValid(repo)
 	if err != nil {
@@ -528,32 +1039,172 @@ func (e *TelegramEndpoint) handlerForceProcess(tokens []string, update *telego.U
 }
 
 func isFilterExists(url, filterName string) bool {
+	return findFilter(url, filterName) != nil
+}
+
+func findFilter(url, filterName string) *configs.FiltersConfig {
 	configs.Config.RLock()
 	defer configs.Config.RUnlock()
 	for _, feed := range configs.Config.FeedsConfig {
 		if feed.Repo == url {
 			for _, feedFilter := range feed.Filters {
 				if feedFilter.Name == filterName {
-					return true
+					return feedFilter
 				}
 			}
 		}
 	}
-	return false
+	return nil
 }
 
-func (e *TelegramEndpoint) handlerSubscribe(tokens []string, update *telego.Update) error {
-	logger := e.logger.With(zap.String("handler", "subscription"))
-	if !e.checkAuthorized(update) {
-		return errUnauthorized
+func formatRules(url, filterName string, rules []*db.FilterRule) string {
+	if len(rules) == 0 {
+		return "no rules defined for `" + url + "` `" + filterName + "`"
+	}
+	response := "rules for `" + url + "` `" + filterName + "`:\n"
+	for i, r := range rules {
+		response += fmt.Sprintf("%v\\. %s %s `%s`\n", i+1, r.Action, r.Field, render.EscapeCode(render.FormatMarkdownV2, r.Pattern))
+	}
+	return response
+}
+
+func (e *TelegramEndpoint) handlerRules(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "rules"))
+	url := args.get("repo")
+	filterName := args.get("filter_name")
+	action := args.get("action")
+
+	if !isFilterExists(url, filterName) {
+		return errors.New("unknown combination of url and filter, use /list to get list of possible feeds")
+	}
+
+	rules, err := e.db.ListFilterRules(url, filterName)
+	if err != nil {
+		logger.Error("error getting filter rules",
+			zap.String("url", url),
+			zap.String("filter_name", filterName),
+			zap.Error(err),
+		)
+		return errors.New("error occurred while trying to get filter rules")
+	}
+
+	if action == "" {
+		if len(args.rest) > 0 {
+			return e.usageError("/rules", "action is required")
+		}
+		return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, formatRules(url, filterName, rules))
+	}
+
+	rest := args.restValues()
+	switch action {
+	case db.RuleActionInclude, db.RuleActionExclude:
+		rule := &db.FilterRule{
+			Action: action,
+			Field:  db.RuleFieldTitle,
+		}
+		// Pattern is taken as is, so it doesn't need to be quoted
+		rule.Pattern = args.restText(0)
+		if len(rest) > 1 && (rest[0] == db.RuleFieldTitle || rest[0] == db.RuleFieldBody) {
+			rule.Field = rest[0]
+			rule.Pattern = args.restText(1)
+		}
+		if rule.Pattern == "" {
+			return e.usageError("/rules", "pattern is required")
+		}
+		rules = append(rules, rule)
+	case "delete":
+		if len(rest) != 1 {
+			return e.usageError("/rules", "rule number is required")
+		}
+		n, err := strconv.Atoi(rest[0])
+		if err != nil || n < 1 || n > len(rules) {
+			return fmt.Errorf("rule number must be between 1 and %v", len(rules))
+		}
+		rules = append(rules[:n-1], rules[n:]...)
+	case "clear":
+		if len(rest) != 0 {
+			return e.usageError("/rules", "clear doesn't have arguments")
+		}
+		rules = nil
 	}
 
-	if len(tokens) != 3 {
-		return errors.New("/subscribe requires exactly 2 arguments\n\n" + e.commands["/subscribe"].description)
+	compiled, err := feeds.CompileRules(rules)
+	if err != nil {
+		return errors.Wrap(err, "invalid rule")
+	}
+
+	err = e.db.SetFilterRules(url, filterName, rules)
+	if err != nil {
+		logger.Error("error updating filter rules",
+			zap.String("url", url),
+			zap.String("filter_name", filterName),
+			zap.Error(err),
+		)
+		return errors.New("error occurred while trying to update filter rules")
+	}
+
+	feeds.UpdateFilterRules(url, filterName, compiled)
+
+	return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, formatRules(url, filterName, rules))
+}
+
+func (e *TelegramEndpoint) handlerSemver(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "semver"))
+	url := args.get("repo")
+	filterName := args.get("filter_name")
+
+	filter := findFilter(url, filterName)
+	if filter == nil {
+		return errors.New("unknown combination of url and filter, use /list to get list of possible feeds")
 	}
 
-	url := tokens[1]
-	filterName := tokens[2]
+	off := args.get("action") == "off"
+	if off && len(args.options) > 0 {
+		return e.usageError("/semver", "off can't be combined with options")
+	}
+	if !off && len(args.options) == 0 {
+		configs.Config.RLock()
+		current := filter.VersionFilter.String()
+		configs.Config.RUnlock()
+		if current == "" {
+			return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, "version filter is not set")
+		}
+		return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, "current version filter: `"+current+"`")
+	}
+
+	var versionFilter *semver.Filter
+	if !off {
+		versionFilter = &semver.Filter{}
+		for key, value := range args.options {
+			err := versionFilter.Set(key, value)
+			if err != nil {
+				return errors.Wrap(err, "invalid version filter")
+			}
+		}
+	}
+
+	err := e.db.SetFeedVersionFilter(filterName, url, versionFilter.String())
+	if err != nil {
+		logger.Error("error updating version filter",
+			zap.String("url", url),
+			zap.String("filter_name", filterName),
+			zap.Error(err),
+		)
+		return errors.New("error occurred while trying to update version filter")
+	}
+
+	feeds.UpdateVersionFilter(url, filterName, versionFilter)
+
+	if versionFilter == nil {
+		return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, "version filter removed")
+	}
+	return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, "version filter set to `"+versionFilter.String()+"`")
+}
+
+func (e *TelegramEndpoint) handlerSubscribe(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "subscription"))
+	url := args.get("repo")
+	filterName := args.get("filter_name")
 
 	found := isFilterExists(url, filterName)
 
@@ -561,16 +1212,31 @@ func (e *TelegramEndpoint) handlerSubscribe(tokens []string, update *telego.Upda
 		return errors.New("unknown combination of url and filter, use /list to get list of possible feeds")
 	}
 
-	chatID := update.Message.Chat.ID
+	backfill := 0
+	if last := args.options["last"]; last != "" {
+		var err error
+		backfill, err = strconv.Atoi(last)
+		if err != nil || backfill < 1 || backfill > maxBackfill {
+			return e.usageError("/subscribe", fmt.Sprintf("last must be a number from 1 to %v", maxBackfill))
+		}
+	}
+
+	chatID, threadID, err := e.targetChat(logger, args, update)
+	if err != nil {
+		return err
+	}
 	if chatID == 0 {
 		logger.Error("chat id is 0, that shouldn't happen", zap.Any("update", update))
 		_ = e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, "failed to subscribe as bot cannot determine chat_id, please try again later")
 		return errors.New("cannot detect chat_id, subscription failed")
 	}
-	err := e.db.AddSubscribtion(TelegramEndpointName, url, filterName, chatID)
+	err = e.db.AddSubscribtion(TelegramEndpointName, url, filterName, chatID, threadID)
 	if err != nil {
 		if errors.Is(err, db.ErrAlreadyExists) {
-			return errors.New("already subscribed")
+			if chatID != update.Message.Chat.ID {
+				return errors.New("already subscribed")
+			}
+			return e.moveToThread(logger, url, filterName, chatID, threadID, update.Message.MessageID)
 		}
 
 		logger.Error("error adding subscription",
@@ -583,24 +1249,406 @@ func (e *TelegramEndpoint) handlerSubscribe(tokens []string, update *telego.Upda
 		return errors.New("error occurred while trying to subscribe")
 	}
 
-	return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, "successfully subscribed")
+	err = e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, "successfully subscribed")
+	if err != nil || backfill == 0 {
+		return err
+	}
+	return e.backfill(logger, url, filterName, chatID, backfill)
 }
 
-func (e *TelegramEndpoint) handlerUnsubscribe(tokens []string, update *telego.Update) error {
-	logger := e.logger.With(zap.String("handler", "unsubscribe"))
-	if !e.checkAuthorized(update) {
-		return errUnauthorized
+// messageThread returns the forum topic message was sent to, 0 if it's general topic or chat doesn't have topics
+func messageThread(msg *telego.Message) int {
+	if !msg.IsTopicMessage {
+		// Replies in chats without topics have thread id as well
+		return 0
+	}
+	return msg.MessageThreadID
+}
+
+// moveToThread makes existing subscription deliver notifications to another forum topic
+func (e *TelegramEndpoint) moveToThread(logger *zap.Logger, url, filterName string, chatID int64, threadID int, messageID int) error {
+	sub, err := e.getSubscription(logger, url, filterName, chatID)
+	if err != nil {
+		return err
+	}
+	if sub.ThreadID == threadID {
+		return errors.New("already subscribed")
+	}
+
+	sub.ThreadID = threadID
+	err = e.updateSubscription(logger, sub)
+	if err != nil {
+		return err
+	}
+	return e.sendMessage(chatID, messageID, "already subscribed, notifications are moved to this topic")
+}
+
+// backfill sends n most recent releases matched by the filter to the newly subscribed chat only
+func (e *TelegramEndpoint) backfill(logger *zap.Logger, url, filterName string, chatID int64, n int) error {
+	sub, err := e.getSubscription(logger, url, filterName, chatID)
+	if err != nil {
+		return err
+	}
+	filter := feeds.FilterCopy(url, filterName)
+	if filter == nil {
+		return errors.New("filter doesn't exist anymore")
+	}
+
+	accept := func(update *types.Update) bool {
+		deliver, _ := sub.Accepts(update.Channels)
+		return deliver
+	}
+	updates, err := feeds.Latest(e.db, url, filter, n, accept)
+	if err != nil {
+		logger.Warn("failed to get releases for backfill",
+			zap.String("url", url),
+			zap.String("filter_name", filterName),
+			zap.Error(err),
+		)
+		return errors.New("subscribed, but failed to get recent releases of " + url)
+	}
+	if len(updates) == 0 {
+		return e.sendThreadMessage(chatID, sub.ThreadID, "no releases matched by the filter yet")
+	}
+
+	for _, update := range updates {
+		_, silent := sub.Accepts(update.Channels)
+		err = e.sendUpdate(logger, update, sub, silent)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatChannels(channels []types.Channel) string {
+	if len(channels) == 0 {
+		return "all"
+	}
+	return types.JoinChannels(channels)
+}
+
+func (e *TelegramEndpoint) handlerChannels(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "channels"))
+	url := args.get("repo")
+	filterName := args.get("filter_name")
+	chatID := update.Message.Chat.ID
+
+	sub, err := e.getSubscription(logger, url, filterName, chatID)
+	if err != nil {
+		return err
+	}
+
+	channels := args.get("channels")
+	silentChannels, hasSilent := args.options["silent"]
+	if channels == "" && !hasSilent {
+		silent := "none"
+		if len(sub.SilentChannels) > 0 {
+			silent = types.JoinChannels(sub.SilentChannels)
+		}
+		return e.sendMessage(chatID, update.Message.MessageID, "channels: `"+formatChannels(sub.Channels)+"`, silent: `"+silent+"`")
+	}
+
+	if channels != "" {
+		sub.Channels, err = types.ParseChannels(channels)
+		if err != nil {
+			return err
+		}
+	}
+	if hasSilent {
+		sub.SilentChannels, err = types.ParseChannels(silentChannels)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = e.updateSubscription(logger, sub)
+	if err != nil {
+		return err
+	}
+
+	return e.sendMessage(chatID, update.Message.MessageID, "subscription will receive `"+formatChannels(sub.Channels)+"` releases")
+}
+
+// getSubscription returns subscription of the chat, errors are suitable to be shown to the user
+func (e *TelegramEndpoint) getSubscription(logger *zap.Logger, url, filterName string, chatID int64) (*db.Subscription, error) {
+	sub, err := e.db.GetSubscription(TelegramEndpointName, url, filterName, chatID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, errors.New("current chat is not subscribed to this repo and filter")
+		}
+		logger.Error("error getting subscription",
+			zap.String("url", url),
+			zap.String("filter_name", filterName),
+			zap.Int64("chat_id", chatID),
+			zap.Error(err),
+		)
+		return nil, errors.New("error occurred while trying to get subscription")
+	}
+	return sub, nil
+}
+
+func (e *TelegramEndpoint) updateSubscription(logger *zap.Logger, sub *db.Subscription) error {
+	err := e.db.UpdateSubscriptionSettings(sub)
+	if err != nil {
+		logger.Error("error updating subscription",
+			zap.String("url", sub.Url),
+			zap.String("filter_name", sub.Filter),
+			zap.Int64("chat_id", sub.ChatID),
+			zap.Error(err),
+		)
+		return errors.New("error occurred while trying to update subscription")
 	}
+	return nil
+}
+
+func formatBool(v bool) string {
+	if v {
+		return "on"
+	}
+	return "off"
+}
+
+func parseBool(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not a valid value, use either 'on' or 'off'", v)
+}
+
+// parseNotesLength parses maximum length of release notes, "max" means as much as fits into the message
+func parseNotesLength(v string) (int, error) {
+	switch strings.ToLower(v) {
+	case "max":
+		return -1, nil
+	case "default":
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > render.MaxMessageLength {
+		return 0, fmt.Errorf("%q is not a valid length, use either 'max', 'default' or number between 1 and %v", v, render.MaxMessageLength)
+	}
+	return n, nil
+}
+
+func formatNotesLength(n int) string {
+	switch {
+	case n < 0:
+		return "max"
+	case n == 0:
+		return "default"
+	}
+	return strconv.Itoa(n)
+}
 
-	if len(tokens) != 3 {
-		return errors.New("/unsubscribe requires exactly 2 arguments\n\n" + e.commands["/unsubscribe"].description)
+// parseMute parses number of days notifications should be muted for
+func parseMute(v string) (time.Time, error) {
+	if v == "off" {
+		return time.Time{}, nil
 	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days <= 0 {
+		return time.Time{}, errors.New("must be off or positive number of days")
+	}
+	return time.Now().Add(time.Duration(days) * 24 * time.Hour), nil
+}
+
+func formatMute(until time.Time) string {
+	if !until.After(time.Now()) {
+		return "off"
+	}
+	return until.Format(time.DateOnly)
+}
 
-	url := tokens[1]
-	filterName := tokens[2]
+func formatSettings(sub *db.Subscription) string {
+	mode, _ := render.ParseNotesMode(sub.NotesMode)
+	return "edits=" + formatBool(sub.NotifyDescriptionChanges) + " length=" + formatNotesLength(sub.NotesLength) + " notes=" + mode.String() + " mute=" + formatMute(sub.MutedUntil)
+}
 
+func (e *TelegramEndpoint) handlerSettings(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "settings"))
+	url := args.get("repo")
+	filterName := args.get("filter_name")
 	chatID := update.Message.Chat.ID
-	err := e.unsubscribe(logger, chatID, url, filterName)
+
+	sub, err := e.getSubscription(logger, url, filterName, chatID)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range args.options {
+		switch key {
+		case "edits":
+			sub.NotifyDescriptionChanges, err = parseBool(value)
+		case "length":
+			sub.NotesLength, err = parseNotesLength(value)
+		case "notes":
+			var mode render.NotesMode
+			mode, err = render.ParseNotesMode(value)
+			sub.NotesMode = mode.String()
+		case "mute":
+			sub.MutedUntil, err = parseMute(value)
+		}
+		if err != nil {
+			return errors.Wrap(err, key)
+		}
+	}
+
+	if len(args.options) > 0 {
+		err = e.updateSubscription(logger, sub)
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.sendMessage(chatID, update.Message.MessageID, "subscription settings: `"+formatSettings(sub)+"`")
+}
+
+// previewUpdate returns the latest known release matched by the filter, or a sample one if there is none
+func (e *TelegramEndpoint) previewUpdate(logger *zap.Logger, url string, filter *configs.FiltersConfig) *types.Update {
+	seen, err := e.db.GetSeenReleases(feeds.FeedURL(url))
+	if err != nil {
+		logger.Warn("failed to get seen releases for preview",
+			zap.String("url", url),
+			zap.Error(err),
+		)
+	}
+
+	var latest *db.SeenRelease
+	for _, r := range seen {
+		if r.Status == db.ReleaseStatusDeleted || filter.FilterRegex == nil || !filter.FilterRegex.MatchString(r.Title) {
+			continue
+		}
+		if latest == nil || r.Updated.After(latest.Updated) {
+			latest = r
+		}
+	}
+	if latest == nil {
+		return render.SampleUpdate(url, filter.Name)
+	}
+
+	return feeds.UpdateFromSeen(url, filter.Name, latest)
+}
+
+func formatTemplate(scope, text string) string {
+	if text == "" {
+		return scope + " template: default\n"
+	}
+	return scope + " template:\n```\n" + render.EscapeCode(render.FormatMarkdownV2, text) + "\n```\n"
+}
+
+func (e *TelegramEndpoint) handlerTemplate(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "template"))
+	url := args.get("repo")
+	filterName := args.get("filter_name")
+	chatID := update.Message.Chat.ID
+
+	filter := findFilter(url, filterName)
+	if filter == nil {
+		return errors.New("unknown combination of url and filter, use /list to get list of possible feeds")
+	}
+
+	configs.Config.RLock()
+	feedPattern := filter.MessagePattern
+	configs.Config.RUnlock()
+	if !render.IsTemplate(feedPattern) {
+		feedPattern = ""
+	}
+
+	// Chat might be not subscribed yet, in that case only feed's template can be changed
+	sub, err := e.db.GetSubscription(TelegramEndpointName, url, filterName, chatID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		logger.Error("error getting subscription",
+			zap.String("url", url),
+			zap.String("filter_name", filterName),
+			zap.Int64("chat_id", chatID),
+			zap.Error(err),
+		)
+		return errors.New("error occurred while trying to get subscription")
+	}
+	subscriptionTemplate := ""
+	if sub != nil {
+		subscriptionTemplate = sub.Template
+	}
+
+	scope := args.get("action")
+	if scope == "" {
+		response := formatTemplate("feed", feedPattern)
+		if sub != nil {
+			response += formatTemplate("chat", subscriptionTemplate)
+		}
+		return e.sendMessage(chatID, update.Message.MessageID, response)
+	}
+
+	if scope != "preview" {
+		// Template is taken as is, with all the spaces and new lines
+		text := args.restText(0)
+		if text == "" {
+			return e.usageError("/template", "template is required")
+		}
+		if text == "reset" {
+			text = ""
+		} else {
+			_, err = render.ParseTemplate(text)
+			if err != nil {
+				return errors.Wrap(err, "invalid template")
+			}
+		}
+
+		if scope == "chat" {
+			if sub == nil {
+				return errors.New("current chat is not subscribed to this repo and filter")
+			}
+			sub.Template = text
+			err = e.updateSubscription(logger, sub)
+			if err != nil {
+				return err
+			}
+			subscriptionTemplate = text
+		} else {
+			if !e.messageHasRole(update.Message, roleFeedManager) {
+				return errUnauthorized
+			}
+			err = e.db.SetFeedMessagePattern(filterName, url, text)
+			if err != nil {
+				logger.Error("error updating message pattern",
+					zap.String("url", url),
+					zap.String("filter_name", filterName),
+					zap.Error(err),
+				)
+				return errors.New("error occurred while trying to update template")
+			}
+			feeds.UpdateMessagePattern(url, filterName, text)
+			feedPattern = text
+		}
+	}
+
+	preview := e.previewUpdate(logger, url, filter)
+	message, err := render.Render(preview, messageTemplate(subscriptionTemplate, feedPattern), e.format)
+	if err != nil {
+		return errors.Wrap(err, "failed to render template")
+	}
+	// Preview is sent without fallback, so formatting errors are reported back
+	return e.sendNotification(&types.NotificationMessage{
+		ChatID:   chatID,
+		ThreadID: messageThread(update.Message),
+		Message:  message,
+	})
+}
+
+func (e *TelegramEndpoint) handlerUnsubscribe(args *commandArgs, update *telego.Update) error {
+	logger := e.logger.With(zap.String("handler", "unsubscribe"))
+	url := args.get("repo")
+	filterName := args.get("filter_name")
+
+	chatID, _, err := e.targetChat(logger, args, update)
+	if err != nil {
+		return err
+	}
+	err = e.unsubscribe(logger, chatID, url, filterName)
 	if err != nil {
 		return err
 	}
@@ -630,7 +1678,7 @@ func (e *TelegramEndpoint) unsubscribe(logger *zap.Logger, chatID int64, url str
 	return nil
 }
 
-func (e *TelegramEndpoint) handlerList(tokens []string, update *telego.Update) error {
+func (e *TelegramEndpoint) handlerList(_ *commandArgs, update *telego.Update) error {
 	responses := make([]string, 0, 4)
 	response := ""
 	feedsPerMessage := 50
@@ -662,21 +1710,33 @@ func (e *TelegramEndpoint) handlerList(tokens []string, update *telego.Update) e
 	return err
 }
 
-func (e *TelegramEndpoint) handlerHelp(_ []string, update *telego.Update) error {
-	response := ""
-	for _, v := range e.commands {
-		if v.hidden {
-			e.logger.Debug("hidden command's help",
-				zap.String("help", v.description),
-			)
+func (e *TelegramEndpoint) handlerHelp(_ *commandArgs, update *telego.Update) error {
+	names := make([]string, 0, len(e.commands))
+	for name := range e.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	response := "Arguments with spaces must be quoted, e\\.x\\. " + "`constraint=\">=2.0.0 <3\"`" + "\n\n"
+	for _, name := range names {
+		v := e.commands[name]
+		if !e.messageHasRole(update.Message, v.role) {
 			continue
 		}
-		response = response + v.description + "\n\n\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\n\n"
+		response = response + v.help() + "\n\n\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\\=\n\n"
 	}
 
 	return e.sendMessage(update.Message.Chat.ID, update.Message.MessageID, response)
 }
 
+// username returns username of the message author, posts in channels don't have one
+func username(from *telego.User) string {
+	if from == nil {
+		return ""
+	}
+	return from.Username
+}
+
 // Return true if we need to continue
 func (e *TelegramEndpoint) checkUnrecoverableSendError(err error) bool {
 	if strings.Contains(err.Error(), "chat not found") ||
@@ -702,7 +1762,7 @@ func (e *TelegramEndpoint) Process() {
 		}
 	} else {
 		updatesChan, err = e.api.UpdatesViaLongPolling(
-			&telego.GetUpdatesParams{},
+			&telego.GetUpdatesParams{AllowedUpdates: allowedUpdates},
 			telego.WithLongPollingBuffer(1024),
 			telego.WithLongPollingUpdateInterval(time.Second*0),
 			telego.WithLongPollingRetryTimeout(time.Second*10),
@@ -720,34 +1780,66 @@ func (e *TelegramEndpoint) Process() {
 			}
 			return
 		case update := <-updatesChan:
+			if update.CallbackQuery != nil {
+				e.handleCallback(logger, update.CallbackQuery)
+				continue
+			}
+			if update.InlineQuery != nil {
+				e.handleInlineQuery(logger, update.InlineQuery)
+				continue
+			}
+			if update.ChatMember != nil {
+				e.handleChatMember(logger, update.ChatMember)
+				continue
+			}
+			if update.MyChatMember != nil {
+				e.handleMyChatMember(logger, update.MyChatMember)
+				continue
+			}
+			if update.Message == nil && update.ChannelPost != nil {
+				// Commands posted in channels are handled the same way as messages
+				update.Message = update.ChannelPost
+			}
 			if update.Message == nil {
 				continue
 			}
+			e.registerAdminCommands(update.Message)
 
 			logger.Debug("got Message",
-				zap.String("from", update.Message.From.Username),
+				zap.String("from", username(update.Message.From)),
 				zap.String("text", update.Message.Text),
 			)
 
-			tokens := strings.Split(update.Message.Text, " ")
+			name, text := splitCommand(update.Message.Text)
 
 			var m string
-			cmd, ok := e.commands[tokens[0]]
+			err = nil
+			cmd, ok := e.commands[strings.ToLower(name)]
 			if !ok {
-				tokens2 := strings.Split(tokens[0], "@")
+				tokens2 := strings.Split(name, "@")
 				if len(tokens2) > 1 {
 					if tokens2[1] == e.selfUser {
-						cmd, ok = e.commands[tokens2[0]]
+						cmd, ok = e.commands[strings.ToLower(tokens2[0])]
 					}
 				}
 			}
 
 			// It's possible that command had bot name explicitly mentioned, that is why that check is here
 			if ok {
-				err = cmd.f(tokens, &update)
-				if err != nil {
-					m = types.MdReplacer.Replace(err.Error())
+				var args *commandArgs
+				if !e.messageHasRole(update.Message, cmd.role) {
+					err = errUnauthorized
+				} else if args, err = cmd.parse(text); err != nil {
+					err = cmd.usageError(err)
+				} else {
+					err = cmd.f(args, &update)
 				}
+			} else if !strings.HasPrefix(update.Message.Text, "/") {
+				// Not a command, but could be an answer to the bot's question
+				err = e.handleConversationMessage(&update)
+			}
+			if err != nil {
+				m = errorMessage(err)
 			}
 
 			if m != "" {
@@ -756,7 +1848,7 @@ func (e *TelegramEndpoint) Process() {
 			if err != nil {
 				logger.Error("error sending Message",
 					zap.Int64("chat_id", update.Message.Chat.ID),
-					zap.String("from", update.Message.From.Username),
+					zap.String("from", username(update.Message.From)),
 					zap.String("text", update.Message.Text),
 					zap.Error(err),
 				)
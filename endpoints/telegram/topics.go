@@ -0,0 +1,40 @@
+package telegram
+
+import "sync"
+
+type topic struct {
+	chatID   int64
+	threadID int
+}
+
+// topics is a set of forum topics, it's used by concurrent sends
+type topics struct {
+	sync.Mutex
+	set map[topic]bool
+}
+
+func newTopics() *topics {
+	return &topics{set: make(map[topic]bool)}
+}
+
+// add returns false if the topic is already in the set
+func (t *topics) add(chatID int64, threadID int) bool {
+	t.Lock()
+	defer t.Unlock()
+	key := topic{chatID: chatID, threadID: threadID}
+	if t.set[key] {
+		return false
+	}
+	t.set[key] = true
+	return true
+}
+
+// thread returns the thread to send to instead of the given one, general topic replaces deleted topics
+func (t *topics) thread(chatID int64, threadID int) int {
+	t.Lock()
+	defer t.Unlock()
+	if t.set[topic{chatID: chatID, threadID: threadID}] {
+		return 0
+	}
+	return threadID
+}
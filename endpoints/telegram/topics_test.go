@@ -0,0 +1,20 @@
+package telegram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopics(t *testing.T) {
+	deleted := newTopics()
+	require.Equal(t, 5, deleted.thread(-100, 5))
+
+	require.True(t, deleted.add(-100, 5))
+	// Every subscription of the send fails on the same topic, only the first one handles it
+	require.False(t, deleted.add(-100, 5))
+
+	require.Equal(t, 0, deleted.thread(-100, 5))
+	require.Equal(t, 6, deleted.thread(-100, 6))
+	require.Equal(t, 5, deleted.thread(-200, 5))
+}
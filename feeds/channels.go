@@ -0,0 +1,86 @@
+package feeds
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/Civil/github2telegram/semver"
+	"github.com/Civil/github2telegram/types"
+)
+
+var (
+	nightlyRe    = regexp.MustCompile(`^(nightly|snapshot|canary|edge|dev|20\d{6})$`)
+	prereleaseRe = regexp.MustCompile(`^(alpha|beta|rc|pre|preview)$`)
+	ltsRe        = regexp.MustCompile(`^lts$`)
+	// titleMarkerRe matches explicit markers in release title, e.x. "Release 20.10 (LTS)" or "v2.0.0 [draft]"
+	titleMarkerRe = regexp.MustCompile(`(?i)[(\[](draft|lts)[)\]]`)
+)
+
+// tagLabels returns lowercase labels of the tag's prefix and pre-release part, e.x. "v", "rc", "1" for "v1.2.0-rc1" or
+// "nightly" for "nightly-20240101". Tags that are not semantic versions are split entirely. Second value is true if
+// tag is a semantic version with pre-release part.
+func tagLabels(tag string) ([]string, bool) {
+	s := tag
+	prerelease := false
+	if v, err := semver.ParseTag(tag, ""); err == nil {
+		name := tag[strings.LastIndex(tag, "/")+1:]
+		if idx := strings.IndexFunc(name, unicode.IsDigit); idx != -1 {
+			name = name[:idx]
+		}
+		s = name + "-" + v.Prerelease
+		prerelease = v.IsPrerelease()
+	}
+
+	var labels []string
+	var label []rune
+	for _, r := range strings.ToLower(s) {
+		letter, digit := unicode.IsLetter(r), unicode.IsDigit(r)
+		// Letters and digits are separated as well, "rc1" is "rc", "1"
+		if len(label) > 0 && (!letter && !digit || letter != unicode.IsLetter(label[len(label)-1])) {
+			labels = append(labels, string(label))
+			label = label[:0]
+		}
+		if letter || digit {
+			label = append(label, r)
+		}
+	}
+	if len(label) > 0 {
+		labels = append(labels, string(label))
+	}
+	return labels, prerelease
+}
+
+func hasLabel(labels []string, re *regexp.Regexp) bool {
+	for _, l := range labels {
+		if re.MatchString(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyRelease returns channels release belongs to. GitHub's atom feed doesn't tell if release is a prerelease, so
+// channel is guessed from the tag's pre-release part. Title is checked only for explicit markers, as it's free-form
+// text. LTS releases are stable as well.
+func classifyRelease(tag, title string) []types.Channel {
+	labels, prerelease := tagLabels(tag)
+	marker := ""
+	if m := titleMarkerRe.FindStringSubmatch(title); m != nil {
+		marker = strings.ToLower(m[1])
+	}
+
+	switch {
+	case marker == "draft":
+		return []types.Channel{types.ChannelDraft}
+	case hasLabel(labels, nightlyRe):
+		return []types.Channel{types.ChannelNightly}
+	case hasLabel(labels, prereleaseRe):
+		return []types.Channel{types.ChannelPrerelease}
+	case marker == "lts" || hasLabel(labels, ltsRe):
+		return []types.Channel{types.ChannelLTS, types.ChannelStable}
+	case prerelease:
+		return []types.Channel{types.ChannelPrerelease}
+	}
+	return []types.Channel{types.ChannelStable}
+}
@@ -0,0 +1,55 @@
+package feeds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Civil/github2telegram/types"
+)
+
+func TestClassifyRelease(t *testing.T) {
+	stable := []types.Channel{types.ChannelStable}
+	prerelease := []types.Channel{types.ChannelPrerelease}
+	nightly := []types.Channel{types.ChannelNightly}
+	lts := []types.Channel{types.ChannelLTS, types.ChannelStable}
+	draft := []types.Channel{types.ChannelDraft}
+
+	tests := []struct {
+		tag   string
+		title string
+		want  []types.Channel
+	}{
+		{tag: "v1.2.0", title: "v1.2.0", want: stable},
+		{tag: "v1.2.0-rc1", title: "v1.2.0-rc1", want: prerelease},
+		{tag: "v1.2.0-beta.2", title: "Beta", want: prerelease},
+		{tag: "1.2.0-alpha", title: "", want: prerelease},
+		{tag: "v1.2.0-next.1", title: "", want: prerelease},
+		{tag: "v2.0.0-nightly.20240101", title: "", want: nightly},
+		{tag: "nightly-20240101", title: "Nightly build", want: nightly},
+		{tag: "canary", title: "", want: nightly},
+		{tag: "v1.2.0-dev3", title: "", want: nightly},
+		{tag: "v20.10.0-lts", title: "", want: lts},
+		{tag: "v20.10.0", title: "Node.js 20.10.0 (LTS)", want: lts},
+		{tag: "v2.0.0", title: "v2.0.0 [Draft]", want: draft},
+		// Words in titles don't change the channel
+		{tag: "v1.0.1", title: "Fix edge case in parser", want: stable},
+		{tag: "v1.0.2", title: "Knowledge base release", want: stable},
+		{tag: "v1.0.3", title: "Alphabetical sorting", want: stable},
+		{tag: "v1.0.4", title: "Add preview pane", want: stable},
+		{tag: "v1.0.5", title: "Draft support for documents", want: stable},
+		{tag: "v1.0.6", title: "Nightly builds are published again", want: stable},
+		// Build metadata isn't a pre-release part
+		{tag: "v1.0.7+20240101", title: "", want: stable},
+		{tag: "release-2024.01", title: "", want: stable},
+		// Tags without digits must not break classification
+		{tag: "x", title: "", want: stable},
+		{tag: "vx", title: "", want: stable},
+		{tag: "*", title: "", want: stable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			require.Equal(t, tt.want, classifyRelease(tt.tag, tt.title), "title %q", tt.title)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package feeds
+
+import (
+	"github.com/mmcdole/gofeed"
+
+	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/types"
+)
+
+// DryRunResult describes what filter does with a single release
+type DryRunResult struct {
+	Tag   string
+	Title string
+	// Reason is why release was rejected, empty if it was matched
+	Reason string
+	// Update is the notification filter would send, nil if release was rejected
+	Update *types.Update
+}
+
+// FilterCopy returns a copy of the running filter that can be safely used for a dry run, nil if there is no such filter
+func FilterCopy(repo, name string) *configs.FiltersConfig {
+	configs.Config.RLock()
+	defer configs.Config.RUnlock()
+	for _, feed := range configs.Config.FeedsConfig {
+		if feed.Repo != repo {
+			continue
+		}
+		for _, filter := range feed.Filters {
+			if filter.Name == name {
+				res := *filter
+				return &res
+			}
+		}
+	}
+	return nil
+}
+
+// DryRun fetches recent releases of the repo and runs them through the whole filter pipeline, oldest first, as if
+// none of them were announced yet. Neither the filter nor the database are changed.
+func DryRun(repo string, filter *configs.FiltersConfig) ([]*DryRunResult, error) {
+	feed, err := gofeed.NewParser().ParseURL(FeedURL(repo))
+	if err != nil {
+		return nil, err
+	}
+	return dryRunItems(repo, filter, chronological(feed.Items)), nil
+}
+
+func dryRunItems(repo string, filter *configs.FiltersConfig, items []*gofeed.Item) []*DryRunResult {
+	res := make([]*DryRunResult, 0, len(items))
+	lastTag := ""
+	for _, item := range items {
+		tag := itemTag(item)
+		r := &DryRunResult{
+			Tag:    tag,
+			Title:  item.Title,
+			Reason: matchRelease(filter, item, tag, lastTag),
+		}
+		if r.Reason == "" {
+			r.Update = newUpdate(repo, filter.Name, tag, item)
+			r.Update.Template = filter.MessagePattern
+			r.Update.PreviousTag = lastTag
+			lastTag = tag
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// Latest returns updates announcing up to n most recent releases matched by the filter and accepted by accept (nil
+// accepts everything), oldest first. Releases already seen by the poller are used if there are any, otherwise the
+// feed is fetched. Neither the filter nor the seen releases are changed, so it doesn't affect what will be announced.
+func Latest(database db.Database, repo string, filter *configs.FiltersConfig, n int, accept func(*types.Update) bool) ([]*types.Update, error) {
+	seen, err := database.GetSeenReleases(FeedURL(repo))
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*gofeed.Item, 0, len(seen))
+	for _, r := range seen {
+		if r.Status != db.ReleaseStatusDeleted {
+			items = append(items, seenItem(r))
+		}
+	}
+	if len(items) == 0 {
+		feed, err := gofeed.NewParser().ParseURL(FeedURL(repo))
+		if err != nil {
+			return nil, err
+		}
+		items = feed.Items
+	}
+
+	return latestUpdates(dryRunItems(repo, filter, chronological(items)), n, accept), nil
+}
+
+// latestUpdates returns updates of up to n last matched releases accepted by accept, oldest first
+func latestUpdates(results []*DryRunResult, n int, accept func(*types.Update) bool) []*types.Update {
+	var res []*types.Update
+	for i := len(results) - 1; i >= 0 && len(res) < n; i-- {
+		if results[i].Update != nil && (accept == nil || accept(results[i].Update)) {
+			res = append(res, results[i].Update)
+		}
+	}
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
@@ -0,0 +1,62 @@
+package feeds
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/semver"
+	"github.com/Civil/github2telegram/types"
+)
+
+func TestDryRunItems(t *testing.T) {
+	item := func(tag string) *gofeed.Item {
+		return &gofeed.Item{Title: tag, Link: "https://github.com/lomik/go-carbon/releases/tag/" + tag}
+	}
+	filter := &configs.FiltersConfig{
+		Name:           "stable",
+		Filter:         "^v",
+		FilterRegex:    regexp.MustCompile("^v"),
+		MessagePattern: "{{.Tag}}",
+		VersionFilter:  &semver.Filter{OnlyGreater: true, ExcludePrereleases: true},
+		LastTag:        "v9.0.0",
+	}
+
+	results := dryRunItems("lomik/go-carbon", filter, []*gofeed.Item{
+		item("v1.0.0"), item("nightly"), item("v1.1.0-rc1"), item("v1.1.0"), item("v1.0.1"),
+	})
+	require.Len(t, results, 5)
+
+	var matched []string
+	for _, r := range results {
+		if r.Update != nil {
+			require.Empty(t, r.Reason)
+			matched = append(matched, r.Tag)
+		} else {
+			require.NotEmpty(t, r.Reason)
+		}
+	}
+	require.Equal(t, []string{"v1.0.0", "v1.1.0"}, matched)
+	require.Equal(t, "regexp doesn't match", results[1].Reason)
+	require.Equal(t, "version filter: prerelease", results[2].Reason)
+	require.Equal(t, "v1.0.0", results[3].Update.PreviousTag)
+	require.Equal(t, "{{.Tag}}", results[3].Update.Template)
+
+	latest := latestUpdates(results, 1, nil)
+	require.Len(t, latest, 1)
+	require.Equal(t, "v1.1.0", latest[0].Tag)
+	latest = latestUpdates(results, 3, nil)
+	require.Len(t, latest, 2)
+	require.Equal(t, "v1.0.0", latest[0].Tag)
+
+	// Releases are filtered before n of them are taken
+	latest = latestUpdates(results, 1, func(u *types.Update) bool { return u.Tag != "v1.1.0" })
+	require.Len(t, latest, 1)
+	require.Equal(t, "v1.0.0", latest[0].Tag)
+
+	// Dry run doesn't change the filter
+	require.Equal(t, "v9.0.0", filter.LastTag)
+}
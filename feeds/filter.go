@@ -0,0 +1,68 @@
+package feeds
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/mmcdole/gofeed"
+
+	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/db"
+)
+
+// CompileRules validates and compiles filter rules stored in database
+func CompileRules(rules []*db.FilterRule) ([]configs.FilterRule, error) {
+	res := make([]configs.FilterRule, 0, len(rules))
+	for i, r := range rules {
+		rule := configs.FilterRule{}
+		switch r.Action {
+		case db.RuleActionInclude:
+		case db.RuleActionExclude:
+			rule.Exclude = true
+		default:
+			return nil, fmt.Errorf("rule %v: unknown action %q, must be either %q or %q", i+1, r.Action, db.RuleActionInclude, db.RuleActionExclude)
+		}
+
+		switch r.Field {
+		case db.RuleFieldTitle:
+		case db.RuleFieldBody:
+			rule.MatchBody = true
+		default:
+			return nil, fmt.Errorf("rule %v: unknown field %q, must be either %q or %q", i+1, r.Field, db.RuleFieldTitle, db.RuleFieldBody)
+		}
+
+		var err error
+		rule.Regex, err = regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %v: %w", i+1, err)
+		}
+		res = append(res, rule)
+	}
+	return res, nil
+}
+
+// matchRules applies rules to the item that was already matched by filter's regex. Rules are checked in order and
+// the last one that matches decides if item is included or excluded, so exclusions can be narrowed by later includes.
+func matchRules(rules []configs.FilterRule, item *gofeed.Item) bool {
+	included := true
+	for _, rule := range rules {
+		text := item.Title
+		if rule.MatchBody {
+			text = item.Content
+			if text == "" {
+				text = item.Description
+			}
+		}
+		if rule.Regex.MatchString(text) {
+			included = !rule.Exclude
+		}
+	}
+	return included
+}
+
+// UpdateFilterRules replaces rules of already running feed. Returns false if filter wasn't found
+func UpdateFilterRules(repo, name string, rules []configs.FilterRule) bool {
+	return updateFilter(repo, name, func(filter *configs.FiltersConfig) {
+		filter.Rules = rules
+	})
+}
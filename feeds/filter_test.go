@@ -0,0 +1,85 @@
+package feeds
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Civil/github2telegram/db"
+)
+
+func TestCompileRules(t *testing.T) {
+	rules, err := CompileRules([]*db.FilterRule{
+		{Action: db.RuleActionInclude, Field: db.RuleFieldTitle, Pattern: "^v1"},
+		{Action: db.RuleActionExclude, Field: db.RuleFieldBody, Pattern: "(?i)internal"},
+	})
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.False(t, rules[0].Exclude)
+	require.False(t, rules[0].MatchBody)
+	require.True(t, rules[1].Exclude)
+	require.True(t, rules[1].MatchBody)
+
+	tests := []struct {
+		name string
+		rule *db.FilterRule
+		err  string
+	}{
+		{
+			name: "unknown action",
+			rule: &db.FilterRule{Action: "drop", Field: db.RuleFieldTitle, Pattern: "rc"},
+			err:  `rule 2: unknown action "drop", must be either "include" or "exclude"`,
+		},
+		{
+			name: "unknown field",
+			rule: &db.FilterRule{Action: db.RuleActionExclude, Field: "author", Pattern: "rc"},
+			err:  `rule 2: unknown field "author", must be either "title" or "body"`,
+		},
+		{
+			name: "invalid regexp",
+			rule: &db.FilterRule{Action: db.RuleActionExclude, Field: db.RuleFieldTitle, Pattern: "("},
+			err:  "rule 2: error parsing regexp: missing closing ): `(`",
+		},
+	}
+
+	valid := &db.FilterRule{Action: db.RuleActionInclude, Field: db.RuleFieldTitle, Pattern: ".*"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileRules([]*db.FilterRule{valid, tt.rule})
+			require.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestMatchRules(t *testing.T) {
+	rules, err := CompileRules([]*db.FilterRule{
+		{Action: db.RuleActionExclude, Field: db.RuleFieldTitle, Pattern: "-rc"},
+		{Action: db.RuleActionInclude, Field: db.RuleFieldTitle, Pattern: "-rc1$"},
+		{Action: db.RuleActionExclude, Field: db.RuleFieldBody, Pattern: "(?i)do not use"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		item *gofeed.Item
+		want bool
+	}{
+		{name: "no rule matches", item: &gofeed.Item{Title: "v1.2.0"}, want: true},
+		{name: "excluded by title", item: &gofeed.Item{Title: "v1.2.0-rc2"}, want: false},
+		{name: "last matching rule wins", item: &gofeed.Item{Title: "v1.2.0-rc1"}, want: true},
+		{name: "excluded by content", item: &gofeed.Item{Title: "v1.2.0-rc1", Content: "Do not use, broken build"}, want: false},
+		{name: "description is used without content", item: &gofeed.Item{Title: "v1.2.0", Description: "do not use"}, want: false},
+		{name: "content is preferred to description", item: &gofeed.Item{Title: "v1.2.0", Content: "Changelog", Description: "do not use"}, want: true},
+		{name: "title rule doesn't match body", item: &gofeed.Item{Title: "v1.2.0", Content: "Fixed in -rc3"}, want: true},
+		{name: "body rule doesn't match title", item: &gofeed.Item{Title: "v1.2.0 do not use"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, matchRules(rules, tt.item))
+		})
+	}
+
+	require.True(t, matchRules(nil, &gofeed.Item{Title: "anything"}))
+}
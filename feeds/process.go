@@ -1,8 +1,12 @@
 package feeds
 
 import (
+	"context"
 	"math/rand"
+	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,23 +16,24 @@ import (
 
 	"github.com/Civil/github2telegram/configs"
 	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/semver"
 
 	"github.com/lomik/zapwriter"
-	"github.com/lunny/html2md"
 	"github.com/mmcdole/gofeed"
 	"go.uber.org/zap"
 )
 
-var runningFeeds = make([]*Feed, 0)
+// runningFeeds are feeds that poll the repos, by repo. Every repo is polled by a single feed, no matter how many filters
+// it has.
+var runningFeeds = make(map[string]*Feed)
 
 func ForceProcessFeed(name string) {
 	configs.Config.RLock()
-	defer configs.Config.RUnlock()
+	f, ok := runningFeeds[name]
+	configs.Config.RUnlock()
 
-	for _, f := range runningFeeds {
-		if f.Repo == name {
-			f.ForceProcess()
-		}
+	if ok {
+		f.ForceProcess()
 	}
 }
 
@@ -37,6 +42,7 @@ func UpdateFeeds(feeds []*Feed) {
 	configs.Config.Lock()
 	defer configs.Config.Unlock()
 
+	var started []*Feed
 	for _, feed := range feeds {
 		logger := loggerRef.With(
 			zap.Int("id", feed.Id),
@@ -54,6 +60,13 @@ func UpdateFeeds(feeds []*Feed) {
 			}
 		}
 
+		if cfg != nil && hasFilter(cfg, feed.Name) {
+			logger.Warn("filter is already running",
+				zap.String("filter_name", feed.Name),
+			)
+			continue
+		}
+
 		re, err := regexp.Compile(feed.Filter)
 		if err != nil {
 			logger.Error("failed to compile regex",
@@ -63,55 +76,181 @@ func UpdateFeeds(feeds []*Feed) {
 			continue
 		}
 
+		versionFilter, err := semver.ParseFilter(feed.VersionFilter)
+		if err != nil {
+			logger.Error("failed to parse version filter",
+				zap.String("version_filter", feed.VersionFilter),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		rules, err := CompileRules(feed.Rules)
+		if err != nil {
+			logger.Error("failed to compile filter rules",
+				zap.Error(err),
+			)
+			continue
+		}
+
 		// We were unable to find relevant configuration for this particular feed, we need to create it
 		if cfg == nil {
 			logger.Debug("creating first configuration for the repo")
-			feed.cfg = configs.FeedsConfig{
+			cfg = &configs.FeedsConfig{
 				Repo:            feed.Repo,
 				PollingInterval: configs.Config.PollingInterval,
-				Filters: []configs.FiltersConfig{{
-					Name:           feed.Name,
-					Filter:         feed.Filter,
-					MessagePattern: feed.MessagePattern,
-					FilterRegex:    re,
-				}},
 			}
-
-			configs.Config.FeedsConfig = append(configs.Config.FeedsConfig, &feed.cfg)
-			continue
+			configs.Config.FeedsConfig = append(configs.Config.FeedsConfig, cfg)
+		} else {
+			logger.Debug("adding new configuration for existing repo")
 		}
 
-		// Configuration was found, but this filter is new, we need to append it to existing repo
-		logger.Debug("adding new configuration for existing repo")
-		cfg.Filters = append(cfg.Filters, configs.FiltersConfig{
+		url := FeedURL(feed.Repo)
+		cfg.Filters = append(cfg.Filters, &configs.FiltersConfig{
 			Name:           feed.Name,
 			Filter:         feed.Filter,
 			MessagePattern: feed.MessagePattern,
 			FilterRegex:    re,
+			VersionFilter:  versionFilter,
+			Rules:          rules,
+			LastUpdateTime: feed.db.GetLastUpdateTime(url, feed.Filter),
+			LastTag:        feed.db.GetLastTag(url, feed.Filter),
 		})
 
-		feed.cfg.Filters = cfg.Filters
+		// New filter of already polled repo is picked up by its feed on the next run
+		if _, ok := runningFeeds[feed.Repo]; !ok {
+			feed.stop = make(chan struct{})
+			runningFeeds[feed.Repo] = feed
+			started = append(started, feed)
+		}
 	}
 
 	loggerRef.Debug("feeds initialized",
 		zap.Any("feeds", feeds),
 	)
 
-	for _, feed := range feeds {
-		// keep track of feeds that are currently running
-		runningFeeds = append(runningFeeds, feed)
-		go func(f *Feed) {
-			f.ProcessFeed()
-			configs.Config.RLock()
-			defer configs.Config.RUnlock()
-			for i, rf := range runningFeeds {
-				if rf.Id == f.Id {
-					runningFeeds = append(runningFeeds[:i], runningFeeds[i+1:]...)
-					break
+	for _, feed := range started {
+		go feed.ProcessFeed()
+	}
+}
+
+func hasFilter(cfg *configs.FeedsConfig, name string) bool {
+	for _, filter := range cfg.Filters {
+		if filter.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// repoConfig returns copy of current configuration of the repo including its filters, so it can be processed without
+// holding the lock. Commands change filters concurrently, so state of the processed filters is written back with
+// saveFilterState. Returns nil if repo has no filters.
+func repoConfig(repo string) *configs.FeedsConfig {
+	configs.Config.RLock()
+	defer configs.Config.RUnlock()
+
+	for _, cfg := range configs.Config.FeedsConfig {
+		if cfg.Repo == repo && len(cfg.Filters) > 0 {
+			res := *cfg
+			res.Filters = make([]*configs.FiltersConfig, 0, len(cfg.Filters))
+			for _, filter := range cfg.Filters {
+				filterCopy := *filter
+				res.Filters = append(res.Filters, &filterCopy)
+			}
+			if res.PollingInterval == 0 {
+				res.PollingInterval = configs.Config.PollingInterval
+			}
+			return &res
+		}
+	}
+	return nil
+}
+
+// RemoveFilter stops processing of the filter, repo is not polled anymore once its last filter is removed. Returns
+// false if filter wasn't found
+func RemoveFilter(repo, name string) bool {
+	configs.Config.Lock()
+	defer configs.Config.Unlock()
+
+	for i, cfg := range configs.Config.FeedsConfig {
+		if cfg.Repo != repo {
+			continue
+		}
+		for j, filter := range cfg.Filters {
+			if filter.Name != name {
+				continue
+			}
+			filters := make([]*configs.FiltersConfig, 0, len(cfg.Filters)-1)
+			filters = append(filters, cfg.Filters[:j]...)
+			cfg.Filters = append(filters, cfg.Filters[j+1:]...)
+
+			if len(cfg.Filters) == 0 {
+				configs.Config.FeedsConfig = append(configs.Config.FeedsConfig[:i], configs.Config.FeedsConfig[i+1:]...)
+				if f, ok := runningFeeds[repo]; ok {
+					close(f.stop)
+					delete(runningFeeds, repo)
 				}
 			}
-		}(feed)
+			return true
+		}
 	}
+	return false
+}
+
+// FeedURL returns URL of the releases feed of the repo
+func FeedURL(repo string) string {
+	return "https://github.com/" + repo + "/releases.atom"
+}
+
+// updateFilter applies change to the filter of already running feed. Returns false if filter wasn't found
+func updateFilter(repo, name string, change func(filter *configs.FiltersConfig)) bool {
+	configs.Config.Lock()
+	defer configs.Config.Unlock()
+
+	for _, cfg := range configs.Config.FeedsConfig {
+		if cfg.Repo != repo {
+			continue
+		}
+		for _, filter := range cfg.Filters {
+			if filter.Name == name {
+				change(filter)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// saveFilterState stores the last announced tag and update time of the processed filter copy into the running
+// configuration, other settings might have been changed by commands in the meantime
+func saveFilterState(repo string, state *configs.FiltersConfig) {
+	updateFilter(repo, state.Name, func(filter *configs.FiltersConfig) {
+		filter.LastTag = state.LastTag
+		filter.LastUpdateTime = state.LastUpdateTime
+	})
+}
+
+// UpdateVersionFilter replaces version filter of already running feed. Returns false if filter wasn't found
+func UpdateVersionFilter(repo, name string, versionFilter *semver.Filter) bool {
+	return updateFilter(repo, name, func(filter *configs.FiltersConfig) {
+		filter.VersionFilter = versionFilter
+	})
+}
+
+// UpdateFilterRegex replaces regexp of already running feed. Returns false if filter wasn't found
+func UpdateFilterRegex(repo, name string, re *regexp.Regexp) bool {
+	return updateFilter(repo, name, func(filter *configs.FiltersConfig) {
+		filter.Filter = re.String()
+		filter.FilterRegex = re
+	})
+}
+
+// UpdateMessagePattern replaces message template of already running feed. Returns false if filter wasn't found
+func UpdateMessagePattern(repo, name, pattern string) bool {
+	return updateFilter(repo, name, func(filter *configs.FiltersConfig) {
+		filter.MessagePattern = pattern
+	})
 }
 
 type Feed struct {
@@ -120,11 +259,14 @@ type Feed struct {
 	Filter         string
 	Name           string
 	MessagePattern string
+	VersionFilter  string
+	Rules          []*db.FilterRule
 
 	db             db.Database
 	lastUpdateTime time.Time
 	logger         *zap.Logger
-	cfg            configs.FeedsConfig
+	// stop is closed when repo doesn't have filters anymore
+	stop chan struct{}
 }
 
 func NewFeed(repo, filter, name, messagePattern string, database db.Database) (*Feed, error) {
@@ -150,202 +292,388 @@ func NewFeed(repo, filter, name, messagePattern string, database db.Database) (*
 	}, nil
 }
 
-func (f *Feed) SetCfg(cfg configs.FeedsConfig) {
-	f.cfg = cfg
-}
-
-func (f *Feed) processSingleItem(cfg *configs.FeedsConfig, url string, item *gofeed.Item) {
-	logger := f.logger.With(
-		zap.String("item_title", item.Title),
-		zap.Int("filters defined", len(cfg.Filters)),
-		zap.Time("item_update_time", *item.UpdatedParsed),
-	)
-	logger.Debug("processing item")
-	for i := range cfg.Filters {
-		logger = logger.With(
-			zap.Int("filter_id", i),
-			zap.String("filter", cfg.Filters[i].Filter),
-			zap.Time("filter_last_update_time", cfg.Filters[i].LastUpdateTime),
-		)
-
-		if cfg.Filters[i].FilterRegex == nil {
-			logger.Error("regex not defined for package",
-				zap.String("reason", "some bug caused filter not to be defined. This should never happen"),
-			)
-			continue
+// itemTag returns release tag, GitHub puts it only in the link, title is a release name
+func itemTag(item *gofeed.Item) string {
+	const tagPath = "/releases/tag/"
+	if idx := strings.LastIndex(item.Link, tagPath); idx != -1 {
+		tag, err := url.PathUnescape(item.Link[idx+len(tagPath):])
+		if err == nil && tag != "" {
+			return tag
 		}
+	}
+	return item.Title
+}
 
-		logger = logger.With(
-			zap.String("filter_regex_string", cfg.Filters[i].FilterRegex.String()),
-		)
+// itemTime returns the time release was last updated, items without any timestamp get zero time
+func itemTime(item *gofeed.Item) time.Time {
+	if item.UpdatedParsed != nil {
+		return *item.UpdatedParsed
+	}
+	if item.PublishedParsed != nil {
+		return *item.PublishedParsed
+	}
+	return time.Time{}
+}
 
-		logger.Debug("will test for filter")
+// chronological returns items sorted from the oldest to the newest one. Feed lists the newest items first, so
+// items without timestamp keep their relative position.
+func chronological(items []*gofeed.Item) []*gofeed.Item {
+	res := make([]*gofeed.Item, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		res = append(res, items[i])
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return itemTime(res[i]).Before(itemTime(res[j]))
+	})
+	return res
+}
 
-		if cfg.Filters[i].LastUpdateTime.Unix() >= item.UpdatedParsed.Unix() {
-			cfg.Filters[i].FilterProcessed = true
+// newUpdate returns update announcing the release
+func newUpdate(repo, filter, tag string, item *gofeed.Item) *types.Update {
+	update := &types.Update{
+		Type:     types.NewRelease,
+		Repo:     repo,
+		Filter:   filter,
+		Tag:      tag,
+		Title:    item.Title,
+		Content:  itemContent(item),
+		Link:     item.Link,
+		Channels: classifyRelease(tag, item.Title),
+	}
+	for _, e := range item.Enclosures {
+		name := e.URL
+		if idx := strings.LastIndex(name, "/"); idx != -1 {
+			name = name[idx+1:]
 		}
+		update.Assets = append(update.Assets, types.Asset{Name: name, URL: e.URL})
+	}
+	return update
+}
 
-		if cfg.Filters[i].FilterProcessed {
-			logger.Debug("item already processed by this filter")
-			continue
-		}
+// UpdateFromSeen returns update announcing already seen release, used to preview notifications
+func UpdateFromSeen(repo, filter string, r *db.SeenRelease) *types.Update {
+	return newUpdate(repo, filter, r.Tag, seenItem(r))
+}
 
-		if cfg.Filters[i].FilterRegex.MatchString(item.Title) {
-			logger.Debug("filter matched")
-			contentTruncated := false
-			var changeType UpdateType
+// matchRelease runs release through filter's regexp, rules and version filter. lastTag is the last announced tag the
+// version filter compares with. Returns the reason release was rejected, empty if it matches.
+func matchRelease(filter *configs.FiltersConfig, item *gofeed.Item, tag, lastTag string) string {
+	if !filter.FilterRegex.MatchString(item.Title) {
+		return "regexp doesn't match"
+	}
 
-			// check if last tag haven't changed
-			if item.Title == cfg.Filters[i].LastTag {
-				changeType = DescriptionChange
+	if !matchRules(filter.Rules, item) {
+		return "excluded by filter rules"
+	}
 
-			} else {
-				changeType = NewRelease
-			}
+	if filter.VersionFilter != nil {
+		matched, reason := filter.VersionFilter.Match(tag, lastTag)
+		if !matched {
+			return "version filter: " + reason
+		}
+	}
+	return ""
+}
 
-			notification := types.MdReplacer.Replace(cfg.Repo) + changeType.String() + types.MdReplacer.Replace(item.Title) + "\nLink: " + types.MdReplacer.Replace(item.Link)
+// matchItem checks if release change should be announced by the filter and returns an update for it. Filter's last
+// tag is advanced on new releases, so changes must be checked in chronological order. During initial sync (nothing
+// was seen in the feed yet) releases older than filter's last update time are skipped.
+func (f *Feed) matchItem(logger *zap.Logger, repo string, filter *configs.FiltersConfig, change *releaseChange, initial bool) *types.Update {
+	item := change.Item
+	t := itemTime(item)
+	logger = logger.With(
+		zap.String("item_title", item.Title),
+		zap.Time("item_update_time", t),
+		zap.Stringer("change_type", change.Type),
+	)
 
-			content := html2md.Convert(item.Content)
-			if len(content) > 250 {
-				content = content[:250] + "\\.\\.\\."
-				contentTruncated = true
-			}
-			content = strings.Replace(content, "```", "", 1)
+	if initial && !filter.LastUpdateTime.Before(t) {
+		logger.Debug("item already processed by this filter")
+		return nil
+	}
 
-			notification += "\nRelease notes:\n```\n" + content + "\n```\n"
-			if contentTruncated {
-				notification += "[More](" + item.Link + ")"
-			}
+	tag := change.Tag
+	// Changes of already announced releases are not compared with the last announced version
+	lastTag := ""
+	if change.Type == types.NewRelease {
+		lastTag = filter.LastTag
+	}
+	if reason := matchRelease(filter, item, tag, lastTag); reason != "" {
+		logger.Debug("filter doesn't match",
+			zap.String("tag", tag),
+			zap.String("last_tag", lastTag),
+			zap.String("reason", reason),
+		)
+		return nil
+	}
 
-			logger.Info("release tagged",
-				zap.String("release", item.Title),
-				zap.String("notification", notification),
-				zap.String("content", item.Content),
-				zap.Any("changeType", changeType),
-			)
+	logger.Debug("filter matched")
+	changeType := change.Type
+
+	update := newUpdate(repo, filter.Name, tag, item)
+	update.Type = changeType
+	update.Template = filter.MessagePattern
+	if change.Previous != nil {
+		update.PreviousTag = change.Previous.Tag
+		update.PreviousContent = change.Previous.Content
+	} else if changeType == types.NewRelease {
+		update.PreviousTag = filter.LastTag
+	}
 
-			methods, err := f.db.GetNotificationMethods(cfg.Repo, cfg.Filters[i].Name)
-			if err != nil {
-				logger.Error("error sending notification",
-					zap.Error(err),
-				)
-				continue
-			}
-			logger.Debug("notifications",
-				zap.Strings("methods", methods),
-			)
-			for _, m := range methods {
-				logger.Debug("will notify",
-					zap.String("method", m),
-					zap.Any("senders", configs.Config.Senders),
-				)
-				err = configs.Config.Senders[m].Send(cfg.Repo, cfg.Filters[i].Name, notification)
-				if err != nil {
-					logger.Error("failed to send an update",
-						zap.Error(err),
-					)
-				}
-			}
+	logger.Info("release tagged",
+		zap.String("release", item.Title),
+		zap.String("content", item.Content),
+		zap.Any("changeType", changeType),
+		zap.Any("channels", update.Channels),
+	)
 
-			cfg.Filters[i].FilterProcessed = true
-			cfg.Filters[i].LastUpdateTime = *item.UpdatedParsed
-			cfg.Filters[i].LastTag = item.Title
-			f.db.UpdateLastUpdateTime(url, cfg.Filters[i].Filter, item.Title, cfg.Filters[i].LastUpdateTime)
-		} else {
-			logger.Debug("filter doesn't match")
-		}
+	if changeType == types.NewRelease {
+		filter.LastTag = tag
 	}
+	if t.After(filter.LastUpdateTime) {
+		filter.LastUpdateTime = t
+	}
+	return update
 }
 
-func (f *Feed) ForceProcess() {
-	cfg := f.cfg
+// processFilter announces all release changes matched by the filter, oldest first. If there are more of them than
+// configured maximum, they are collapsed into a single summary message. During initial sync only the newest release
+// is announced, there is no point to announce whole history of the repo. Returns error if changes weren't announced
+// and must be processed again on the next poll.
+func (f *Feed) processFilter(repo string, filter *configs.FiltersConfig, url string, changes []*releaseChange, initial bool) error {
+	logger := f.logger.With(
+		zap.String("filter", filter.Filter),
+		zap.String("filter_name", filter.Name),
+		zap.Time("filter_last_update_time", filter.LastUpdateTime),
+	)
 
-	if len(cfg.Filters) == 0 {
-		f.logger.Warn("no filters to process, exiting",
-			zap.Any("cfg", cfg),
+	if filter.FilterRegex == nil {
+		logger.Error("regex not defined for package",
+			zap.String("reason", "some bug caused filter not to be defined. This should never happen"),
 		)
-		return
+		// Filter won't be fixed by the next poll, retrying would only block other filters
+		return nil
 	}
 
-	url := "https://github.com/" + f.Repo + "/releases.atom"
+	logger.Debug("will test for filter")
 
-	// Initialize
-	for i := range cfg.Filters {
-		cfg.Filters[i].LastUpdateTime = f.db.GetLastUpdateTime(url, cfg.Filters[i].Filter)
-		cfg.Filters[i].LastTag = f.db.GetLastTag(url, cfg.Filters[i].Filter)
+	var updates []*types.Update
+	for _, change := range changes {
+		update := f.matchItem(logger, repo, filter, change, initial)
+		if update != nil {
+			updates = append(updates, update)
+		}
 	}
 
-	fp := gofeed.NewParser()
-	if cfg.PollingInterval == 0 {
-		cfg.PollingInterval = configs.Config.PollingInterval
+	if len(updates) == 0 {
+		return nil
 	}
 
-	f.logger.Info("force process triggered",
-		zap.Int("filters", len(cfg.Filters)),
+	if initial {
+		updates = updates[len(updates)-1:]
+	}
+
+	maxReleases := configs.Config.MaxReleasesPerPoll
+	if maxReleases > 0 && len(updates) > maxReleases {
+		logger.Info("too many releases since last check, collapsing them into summary",
+			zap.Int("releases", len(updates)),
+			zap.Int("max_releases_per_poll", maxReleases),
+		)
+		summary := &types.Update{
+			Type:      types.ReleasesSummary,
+			Repo:      repo,
+			Filter:    filter.Name,
+			Tag:       updates[len(updates)-1].Tag,
+			Title:     updates[len(updates)-1].Title,
+			Link:      updates[len(updates)-1].Link,
+			Collapsed: updates,
+			Template:  filter.MessagePattern,
+		}
+		for _, u := range updates {
+			for _, c := range u.Channels {
+				if !types.HasChannel(summary.Channels, c) {
+					summary.Channels = append(summary.Channels, c)
+				}
+			}
+		}
+		updates = []*types.Update{summary}
+	}
+
+	methods, err := f.db.GetNotificationMethods(repo, filter.Name)
+	if err != nil {
+		logger.Error("error sending notification",
+			zap.Error(err),
+		)
+		return err
+	}
+	logger.Debug("notifications",
+		zap.Strings("methods", methods),
 	)
 
-	t0 := time.Now()
-	for i := range cfg.Filters {
-		cfg.Filters[i].FilterProcessed = false
+	for _, update := range updates {
+		for _, m := range methods {
+			logger.Debug("will notify",
+				zap.String("method", m),
+				zap.String("tag", update.Tag),
+			)
+			err = configs.Config.Senders[m].Send(update)
+			if err != nil {
+				logger.Error("failed to send an update",
+					zap.Error(err),
+				)
+			}
+		}
 	}
 
+	saveFilterState(repo, filter)
+	f.db.UpdateLastUpdateTime(url, filter.Filter, filter.LastTag, filter.LastUpdateTime)
+	return nil
+}
+
+// fetch downloads the feed and processes all filters. Returns false if feed is not available anymore
+func (f *Feed) fetch(fp *gofeed.Parser, cfg *configs.FeedsConfig, url string) bool {
+	t0 := time.Now()
 	feed, err := fp.ParseURL(url)
 	if err != nil {
-		f.logger.Error("feed fetch failed ", zap.Duration("runtime", time.Since(t0)),
+		f.logger.Error("feed fetch failed ",
 			zap.Duration("runtime", time.Since(t0)),
 			zap.Time("now", t0),
 			zap.Error(err),
 		)
-		if strings.Contains(err.Error(), "404 Not Found") {
-			err = f.db.RemoveFeed(f.Name, f.Repo, f.Filter, f.MessagePattern)
-			if err != nil {
-				f.logger.Error("error removing feed", zap.Error(err))
-			}
-		}
-		return
+		return !strings.Contains(err.Error(), "404 Not Found")
 	}
 
 	f.logger.Debug("received some data",
 		zap.Int("items", len(feed.Items)),
 	)
 
-	processedFilters := 0
-	for _, item := range feed.Items {
-		f.processSingleItem(&cfg, url, item)
+	seen, err := f.db.GetSeenReleases(url)
+	if err != nil {
+		f.logger.Error("failed to get seen releases",
+			zap.Error(err),
+		)
+		return true
+	}
+
+	changes := detectChanges(seen, chronological(feed.Items))
+	changes, checked := f.verifyDeleted(changes)
+	initial := len(seen) == 0
+	f.logger.Debug("detected release changes",
+		zap.Int("changes", len(changes)),
+		zap.Bool("initial", initial),
+	)
 
-		if len(cfg.Filters) == processedFilters {
-			break
+	failed := false
+	for _, filter := range cfg.Filters {
+		if f.processFilter(cfg.Repo, filter, url, changes, initial) != nil {
+			failed = true
 		}
 	}
+	// Changes are not marked as seen if any filter failed, so the next poll detects them again and retries
+	if failed {
+		f.logger.Warn("failed to process some filters, release changes will be processed again on the next poll")
+	} else {
+		for _, change := range changes {
+			checked = append(checked, change.Seen)
+		}
+	}
+	for _, release := range checked {
+		err = f.db.MarkReleaseSeen(url, release)
+		if err != nil {
+			f.logger.Error("failed to mark release as seen",
+				zap.String("release_id", release.ReleaseID),
+				zap.Error(err),
+			)
+		}
+	}
+
 	f.logger.Info("done",
 		zap.Duration("runtime", time.Since(t0)),
 		zap.Time("now", t0),
 	)
+	return true
 }
 
-func (f *Feed) ProcessFeed() {
-	cfg := f.cfg
+// releaseCheckTimeout limits time spent checking if release that disappeared from the feed still exists
+const releaseCheckTimeout = 30 * time.Second
+
+var releaseCheckClient = &http.Client{Timeout: releaseCheckTimeout}
+
+// verifyDeleted drops deleted releases that are still available, they were just pushed out of the feed by newer ones.
+// Such releases are returned separately to record the check, so they are not checked again on every poll.
+func (f *Feed) verifyDeleted(changes []*releaseChange) ([]*releaseChange, []*db.SeenRelease) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-f.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	res := changes[:0]
+	var checked []*db.SeenRelease
+	for _, change := range changes {
+		if change.Type == types.Deleted && releaseExists(ctx, change.Item.Link) {
+			release := *change.Previous
+			release.CheckedAt = time.Now()
+			checked = append(checked, &release)
+			continue
+		}
+		res = append(res, change)
+	}
+	return res, checked
+}
 
-	if len(cfg.Filters) == 0 {
-		f.logger.Warn("no filters to process, exiting",
-			zap.Any("cfg", cfg),
-		)
+// releaseExists returns false only if release page is gone, any other error is treated as release still exists
+func releaseExists(ctx context.Context, link string) bool {
+	if link == "" {
+		return true
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return true
+	}
+	resp, err := releaseCheckClient.Do(req)
+	if err != nil {
+		return true
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusGone
+}
+
+func (f *Feed) ForceProcess() {
+	cfg := repoConfig(f.Repo)
+	if cfg == nil {
+		f.logger.Warn("no filters to process, exiting")
 		return
 	}
 
-	url := "https://github.com/" + f.Repo + "/releases.atom"
+	url := FeedURL(f.Repo)
+	f.logger.Info("force process triggered",
+		zap.Int("filters", len(cfg.Filters)),
+	)
 
-	// Initialize
-	for i := range cfg.Filters {
-		cfg.Filters[i].LastUpdateTime = f.db.GetLastUpdateTime(url, cfg.Filters[i].Filter)
-		cfg.Filters[i].LastTag = f.db.GetLastTag(url, cfg.Filters[i].Filter)
+	// Filters of the repo are not removed even if it's gone, same as during regular polling, as fetch failure might
+	// be transient
+	if !f.fetch(gofeed.NewParser(), cfg, url) {
+		f.logger.Info("feed should be removed")
 	}
+}
 
-	fp := gofeed.NewParser()
-	if cfg.PollingInterval == 0 {
-		cfg.PollingInterval = configs.Config.PollingInterval
+// ProcessFeed polls the repo until all of its filters are removed. Filters are re-read before every poll, so
+// changes made by commands are applied without restart.
+func (f *Feed) ProcessFeed() {
+	cfg := repoConfig(f.Repo)
+	if cfg == nil {
+		f.logger.Warn("no filters to process, exiting")
+		return
 	}
 
+	url := FeedURL(f.Repo)
+	fp := gofeed.NewParser()
+
 	delay := time.Duration(rand.Int()) % cfg.PollingInterval
 	t0 := time.Now()
 	nextRun := t0.Add(delay)
@@ -356,52 +684,28 @@ func (f *Feed) ProcessFeed() {
 	)
 
 	for {
-		dt := time.Until(nextRun)
-		if dt > 0 {
-			time.Sleep(dt)
-		}
-		nextRun = nextRun.Add(cfg.PollingInterval)
-		t0 = time.Now()
-		for i := range cfg.Filters {
-			cfg.Filters[i].FilterProcessed = false
+		select {
+		case <-time.After(time.Until(nextRun)):
+		case <-f.stop:
+			f.logger.Info("all filters were removed, stopping")
+			return
 		}
 
-		feed, err := fp.ParseURL(url)
-		if err != nil {
-			f.logger.Error("feed fetch failed ", zap.Duration("runtime", time.Since(t0)),
-				zap.Duration("runtime", time.Since(t0)),
-				zap.Time("nextRun", nextRun),
-				zap.Time("now", t0),
-				zap.Error(err),
-			)
-			if strings.Contains(err.Error(), "404 Not Found") {
-//				err = f.db.RemoveFeed(f.Name, f.Repo, f.Filter, f.MessagePattern)
-//				if err != nil {
-//					f.logger.Error("error removing feed", zap.Error(err))
-//					continue
-//				}
-				f.logger.Info("feed should be removed")
-//				return
-			}
-			continue
+		cfg = repoConfig(f.Repo)
+		if cfg == nil {
+			f.logger.Info("all filters were removed, stopping")
+			return
 		}
+		nextRun = nextRun.Add(cfg.PollingInterval)
 
-		f.logger.Debug("received some data",
-			zap.Int("items", len(feed.Items)),
-		)
-
-		processedFilters := 0
-		for _, item := range feed.Items {
-			f.processSingleItem(&cfg, url, item)
-
-			if len(cfg.Filters) == processedFilters {
-				break
-			}
+		if !f.fetch(fp, cfg, url) {
+			//			err = f.db.RemoveFeed(f.Name, f.Repo, f.Filter, f.MessagePattern)
+			//			if err != nil {
+			//				f.logger.Error("error removing feed", zap.Error(err))
+			//				continue
+			//			}
+			f.logger.Info("feed should be removed")
+			//			return
 		}
-		f.logger.Info("done",
-			zap.Duration("runtime", time.Since(t0)),
-			zap.Time("nextRun", nextRun),
-			zap.Time("now", t0),
-		)
 	}
 }
@@ -0,0 +1,294 @@
+package feeds
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/Civil/github2telegram/configs"
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/types"
+)
+
+func TestRemoveFilter(t *testing.T) {
+	repo := "lomik/go-carbon"
+	feed := &Feed{Repo: repo, stop: make(chan struct{})}
+	configs.Config.FeedsConfig = []*configs.FeedsConfig{{
+		Repo: repo,
+		Filters: []*configs.FiltersConfig{
+			{Name: "all", Filter: ".*", FilterRegex: regexp.MustCompile(".*")},
+			{Name: "stable", Filter: "^v", FilterRegex: regexp.MustCompile("^v")},
+		},
+	}}
+	runningFeeds[repo] = feed
+	defer func() {
+		configs.Config.FeedsConfig = nil
+		delete(runningFeeds, repo)
+	}()
+
+	require.True(t, UpdateFilterRegex(repo, "stable", regexp.MustCompile(`^v[0-9]+\.`)))
+	cfg := repoConfig(repo)
+	require.Len(t, cfg.Filters, 2)
+	require.Equal(t, `^v[0-9]+\.`, cfg.Filters[1].Filter)
+
+	require.False(t, RemoveFilter(repo, "unknown"))
+	require.True(t, RemoveFilter(repo, "all"))
+	cfg = repoConfig(repo)
+	require.Len(t, cfg.Filters, 1)
+	require.Equal(t, "stable", cfg.Filters[0].Name)
+	require.Contains(t, runningFeeds, repo)
+
+	require.True(t, RemoveFilter(repo, "stable"))
+	require.Nil(t, repoConfig(repo))
+	require.NotContains(t, runningFeeds, repo)
+	_, open := <-feed.stop
+	require.False(t, open)
+}
+
+func TestRepoConfigCopy(t *testing.T) {
+	repo := "lomik/go-carbon"
+	configs.Config.FeedsConfig = []*configs.FeedsConfig{{
+		Repo:    repo,
+		Filters: []*configs.FiltersConfig{{Name: "all", Filter: ".*", FilterRegex: regexp.MustCompile(".*"), LastTag: "v1.0.0"}},
+	}}
+	defer func() {
+		configs.Config.FeedsConfig = nil
+	}()
+
+	cfg := repoConfig(repo)
+	cfg.Filters[0].LastTag = "v1.1.0"
+	cfg.Filters[0].MessagePattern = "changed by poller"
+	require.Equal(t, "v1.0.0", configs.Config.FeedsConfig[0].Filters[0].LastTag)
+
+	// Only the state is written back, settings changed by commands are kept
+	require.True(t, UpdateMessagePattern(repo, "all", "{{.Tag}}"))
+	saveFilterState(repo, cfg.Filters[0])
+	require.Equal(t, "v1.1.0", configs.Config.FeedsConfig[0].Filters[0].LastTag)
+	require.Equal(t, "{{.Tag}}", configs.Config.FeedsConfig[0].Filters[0].MessagePattern)
+}
+
+// testDatabase stores the state saved by the poller, other methods are not used by it
+type testDatabase struct {
+	db.Database
+	methodsErr     error
+	lastTag        string
+	lastUpdateTime time.Time
+	seen           []*db.SeenRelease
+}
+
+func (d *testDatabase) GetNotificationMethods(_, _ string) ([]string, error) {
+	if d.methodsErr != nil {
+		return nil, d.methodsErr
+	}
+	return []string{"test"}, nil
+}
+
+func (d *testDatabase) GetSeenReleases(_ string) ([]*db.SeenRelease, error) {
+	return d.seen, nil
+}
+
+func (d *testDatabase) MarkReleaseSeen(_ string, release *db.SeenRelease) error {
+	d.seen = append(d.seen, release)
+	return nil
+}
+
+func (d *testDatabase) UpdateLastUpdateTime(_, _, tag string, t time.Time) {
+	d.lastTag = tag
+	d.lastUpdateTime = t
+}
+
+type testSender struct {
+	updates []*types.Update
+}
+
+func (s *testSender) Send(update *types.Update) error {
+	s.updates = append(s.updates, update)
+	return nil
+}
+
+func (s *testSender) Process() {}
+
+func TestProcessFilter(t *testing.T) {
+	repo := "lomik/go-carbon"
+	t0 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// Feed lists the newest releases first
+	items := []*gofeed.Item{
+		testItem("v1.2.0", "", t0.Add(2*time.Hour)),
+		testItem("v1.1.0", "", t0.Add(time.Hour)),
+		testItem("v1.0.0", "", t0),
+	}
+
+	maxReleases := configs.Config.MaxReleasesPerPoll
+	defer func() {
+		configs.Config.FeedsConfig = nil
+		configs.Config.Senders = nil
+		configs.Config.MaxReleasesPerPoll = maxReleases
+	}()
+
+	tests := []struct {
+		name           string
+		initial        bool
+		lastUpdateTime time.Time
+		maxReleases    int
+		want           []string
+		wantPrevious   []string
+	}{
+		{
+			name:         "releases are announced oldest first",
+			maxReleases:  5,
+			want:         []string{"v1.0.0", "v1.1.0", "v1.2.0"},
+			wantPrevious: []string{"", "v1.0.0", "v1.1.0"},
+		},
+		{
+			name:         "initial sync announces only the newest release",
+			initial:      true,
+			maxReleases:  5,
+			want:         []string{"v1.2.0"},
+			wantPrevious: []string{"v1.1.0"},
+		},
+		{
+			name:           "initial sync skips releases processed before",
+			initial:        true,
+			lastUpdateTime: t0.Add(2 * time.Hour),
+			maxReleases:    5,
+		},
+		{
+			name:         "no limit of releases",
+			want:         []string{"v1.0.0", "v1.1.0", "v1.2.0"},
+			wantPrevious: []string{"", "v1.0.0", "v1.1.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := &configs.FiltersConfig{Name: "all", Filter: ".*", FilterRegex: regexp.MustCompile(".*"), LastUpdateTime: tt.lastUpdateTime}
+			configs.Config.FeedsConfig = []*configs.FeedsConfig{{Repo: repo, Filters: []*configs.FiltersConfig{filter}}}
+			sender := &testSender{}
+			configs.Config.Senders = map[string]configs.NotificationEndpoints{"test": sender}
+			configs.Config.MaxReleasesPerPoll = tt.maxReleases
+			database := &testDatabase{}
+			f := &Feed{Repo: repo, db: database, logger: zap.NewNop()}
+
+			err := f.processFilter(repo, repoConfig(repo).Filters[0], FeedURL(repo), detectChanges(nil, chronological(items)), tt.initial)
+			require.NoError(t, err)
+
+			var tags, previous []string
+			for _, u := range sender.updates {
+				require.Equal(t, types.NewRelease, u.Type)
+				tags = append(tags, u.Tag)
+				previous = append(previous, u.PreviousTag)
+			}
+			require.Equal(t, tt.want, tags)
+			require.Equal(t, tt.wantPrevious, previous)
+			if len(tt.want) == 0 {
+				require.Empty(t, database.lastTag)
+				return
+			}
+			require.Equal(t, "v1.2.0", database.lastTag)
+			require.Equal(t, t0.Add(2*time.Hour), database.lastUpdateTime)
+			require.Equal(t, "v1.2.0", filter.LastTag)
+		})
+	}
+}
+
+func TestProcessFilterSummary(t *testing.T) {
+	repo := "lomik/go-carbon"
+	t0 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	items := []*gofeed.Item{
+		testItem("v1.2.0-rc1", "", t0.Add(2*time.Hour)),
+		testItem("v1.1.0", "", t0.Add(time.Hour)),
+		testItem("v1.0.0", "", t0),
+	}
+
+	maxReleases := configs.Config.MaxReleasesPerPoll
+	defer func() {
+		configs.Config.FeedsConfig = nil
+		configs.Config.Senders = nil
+		configs.Config.MaxReleasesPerPoll = maxReleases
+	}()
+
+	filter := &configs.FiltersConfig{Name: "all", Filter: ".*", FilterRegex: regexp.MustCompile(".*"), MessagePattern: "{{.Tag}}"}
+	configs.Config.FeedsConfig = []*configs.FeedsConfig{{Repo: repo, Filters: []*configs.FiltersConfig{filter}}}
+	sender := &testSender{}
+	configs.Config.Senders = map[string]configs.NotificationEndpoints{"test": sender}
+	configs.Config.MaxReleasesPerPoll = 2
+	database := &testDatabase{}
+	f := &Feed{Repo: repo, db: database, logger: zap.NewNop()}
+
+	err := f.processFilter(repo, repoConfig(repo).Filters[0], FeedURL(repo), detectChanges(nil, chronological(items)), false)
+	require.NoError(t, err)
+
+	require.Len(t, sender.updates, 1)
+	summary := sender.updates[0]
+	require.Equal(t, types.ReleasesSummary, summary.Type)
+	require.Equal(t, "v1.2.0-rc1", summary.Tag)
+	require.Equal(t, items[0].Link, summary.Link)
+	require.Equal(t, "{{.Tag}}", summary.Template)
+	require.Equal(t, []types.Channel{types.ChannelStable, types.ChannelPrerelease}, summary.Channels)
+	require.Len(t, summary.Collapsed, 3)
+	require.Equal(t, "v1.0.0", summary.Collapsed[0].Tag)
+	require.Equal(t, "v1.2.0-rc1", summary.Collapsed[2].Tag)
+
+	// Collapsed releases are still recorded as announced
+	require.Equal(t, "v1.2.0-rc1", database.lastTag)
+	require.Equal(t, "v1.2.0-rc1", filter.LastTag)
+}
+
+func TestFetchRetriesFailedFilters(t *testing.T) {
+	repo := "lomik/go-carbon"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml")
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Release notes from go-carbon</title>
+  <entry>
+    <id>tag:github.com,2008:Repository/1/v1.1.0</id>
+    <updated>2024-01-02T00:00:00Z</updated>
+    <link rel="alternate" type="text/html" href="https://github.com/lomik/go-carbon/releases/tag/v1.1.0"/>
+    <title>v1.1.0</title>
+  </entry>
+  <entry>
+    <id>tag:github.com,2008:Repository/1/v1.0.0</id>
+    <updated>2024-01-01T00:00:00Z</updated>
+    <link rel="alternate" type="text/html" href="https://github.com/lomik/go-carbon/releases/tag/v1.0.0"/>
+    <title>v1.0.0</title>
+  </entry>
+</feed>`))
+	}))
+	defer server.Close()
+
+	filter := &configs.FiltersConfig{Name: "all", Filter: ".*", FilterRegex: regexp.MustCompile(".*")}
+	configs.Config.FeedsConfig = []*configs.FeedsConfig{{Repo: repo, Filters: []*configs.FiltersConfig{filter}}}
+	sender := &testSender{}
+	configs.Config.Senders = map[string]configs.NotificationEndpoints{"test": sender}
+	defer func() {
+		configs.Config.FeedsConfig = nil
+		configs.Config.Senders = nil
+	}()
+	// Releases were seen before, so the poll isn't the initial sync and all new releases are announced
+	database := &testDatabase{
+		methodsErr: errors.New("database is locked"),
+		seen:       []*db.SeenRelease{{ReleaseID: "tag:github.com,2008:Repository/1/v0.9.0", Tag: "v0.9.0"}},
+	}
+	f := &Feed{Repo: repo, db: database, logger: zap.NewNop()}
+
+	require.True(t, f.fetch(gofeed.NewParser(), repoConfig(repo), server.URL))
+	require.Empty(t, sender.updates)
+	require.Len(t, database.seen, 1, "releases must not be marked as seen if they weren't announced")
+	require.Empty(t, filter.LastTag)
+
+	database.methodsErr = nil
+	require.True(t, f.fetch(gofeed.NewParser(), repoConfig(repo), server.URL))
+	require.Len(t, sender.updates, 2)
+	require.Equal(t, "v1.0.0", sender.updates[0].Tag)
+	require.Equal(t, "v1.1.0", sender.updates[1].Tag)
+	require.Len(t, database.seen, 3)
+	require.Equal(t, "v1.1.0", filter.LastTag)
+}
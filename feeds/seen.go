@@ -0,0 +1,208 @@
+package feeds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/types"
+)
+
+// releaseChange is a release that is new or was changed since it was seen last time
+type releaseChange struct {
+	Type types.UpdateType
+	Item *gofeed.Item
+	Tag  string
+	// Previous is the state release had when it was seen last time, nil for new releases
+	Previous *db.SeenRelease
+	Seen     *db.SeenRelease
+}
+
+// releaseID returns unique id of the release in the feed
+func releaseID(item *gofeed.Item) string {
+	if item.GUID != "" {
+		return item.GUID
+	}
+	if item.Link != "" {
+		return item.Link
+	}
+	return itemTag(item)
+}
+
+func itemContent(item *gofeed.Item) string {
+	if item.Content != "" {
+		return item.Content
+	}
+	return item.Description
+}
+
+func contentHash(item *gofeed.Item) string {
+	h := sha256.Sum256([]byte(itemContent(item)))
+	return hex.EncodeToString(h[:])
+}
+
+func newSeenRelease(item *gofeed.Item) *db.SeenRelease {
+	return &db.SeenRelease{
+		ReleaseID:   releaseID(item),
+		Tag:         itemTag(item),
+		Title:       item.Title,
+		Link:        item.Link,
+		ContentHash: contentHash(item),
+		Updated:     itemTime(item),
+		Content:     itemContent(item),
+	}
+}
+
+// yankedRe matches markers like "[yanked]", "(retracted)" or "YANKED:" at the beginning of the title, words in the
+// middle of the title are not enough
+var yankedRe = regexp.MustCompile(`(?i)[\[(](yanked|retracted|withdrawn)[\])]|^\W*(yanked|retracted|withdrawn)\W*[:\-–—]`)
+
+// isYanked returns true if release was marked as yanked or retracted. GitHub doesn't have such status, so maintainers
+// usually put a marker in the title
+func isYanked(item *gofeed.Item) bool {
+	return yankedRe.MatchString(item.Title)
+}
+
+// seenItem restores feed item from the seen release, used for releases that are not in the feed anymore
+func seenItem(r *db.SeenRelease) *gofeed.Item {
+	updated := r.Updated
+	return &gofeed.Item{
+		GUID:          r.ReleaseID,
+		Title:         r.Title,
+		Link:          r.Link,
+		Content:       r.Content,
+		UpdatedParsed: &updated,
+	}
+}
+
+// detectChanges compares items with releases that were already seen and returns the ones that are new or changed,
+// keeping the order of items.
+//
+// Release with unknown id is either new, or re-tagged if its notes are the same as the ones of known release that is
+// not in the feed anymore (GitHub includes the tag in entry id). Known release is re-tagged if its tag was changed,
+// yanked if it was marked as such and its description was changed if its title or notes were changed.
+//
+// Releases that disappeared from the feed, but are not too old to be there, are returned as deleted after all other
+// changes. Their Seen state is already marked as deleted, but it's up to the caller to verify that they are really
+// gone and not just pushed out of the feed.
+func detectChanges(seen []*db.SeenRelease, items []*gofeed.Item) []*releaseChange {
+	byID := make(map[string]*db.SeenRelease, len(seen))
+	byHash := make(map[string]*db.SeenRelease, len(seen))
+	emptyHash := contentHash(&gofeed.Item{})
+	for _, r := range seen {
+		byID[r.ReleaseID] = r
+		if r.ContentHash != emptyHash && r.Status != db.ReleaseStatusDeleted {
+			byHash[r.ContentHash] = r
+		}
+	}
+
+	inFeed := make(map[string]bool, len(items))
+	var oldest time.Time
+	for i, item := range items {
+		inFeed[releaseID(item)] = true
+		if t := itemTime(item); i == 0 || t.Before(oldest) {
+			oldest = t
+		}
+	}
+
+	// Release disappeared from the feed, but it's not because it's too old to be there. Releases that were already
+	// verified to exist are not checked again.
+	disappeared := func(r *db.SeenRelease) bool {
+		return r.Status != db.ReleaseStatusDeleted && r.CheckedAt.IsZero() && !inFeed[r.ReleaseID] && !r.Updated.Before(oldest)
+	}
+
+	var changes []*releaseChange
+	renamed := make(map[string]bool)
+	for _, item := range items {
+		current := newSeenRelease(item)
+		change := &releaseChange{
+			Item: item,
+			Tag:  current.Tag,
+			Seen: current,
+		}
+
+		yanked := isYanked(item)
+		if yanked {
+			current.Status = db.ReleaseStatusYanked
+		}
+
+		previous, ok := byID[current.ReleaseID]
+		switch {
+		case !ok || previous.Status == db.ReleaseStatusDeleted:
+			change.Type = types.NewRelease
+			if r, ok := byHash[current.ContentHash]; ok && disappeared(r) && r.Tag != current.Tag {
+				change.Type = types.Retag
+				change.Previous = r
+				renamed[r.ReleaseID] = true
+			}
+		case yanked && previous.Status != db.ReleaseStatusYanked:
+			change.Type = types.Yanked
+			change.Previous = previous
+		case previous.Tag != current.Tag:
+			change.Type = types.Retag
+			change.Previous = previous
+		case previous.ContentHash != current.ContentHash || previous.Title != current.Title:
+			change.Type = types.DescriptionChange
+			change.Previous = previous
+		default:
+			continue
+		}
+
+		changes = append(changes, change)
+	}
+
+	for _, r := range seen {
+		if !disappeared(r) || renamed[r.ReleaseID] {
+			continue
+		}
+		deleted := *r
+		deleted.Status = db.ReleaseStatusDeleted
+		changes = append(changes, &releaseChange{
+			Type:     types.Deleted,
+			Item:     seenItem(r),
+			Tag:      r.Tag,
+			Previous: r,
+			Seen:     &deleted,
+		})
+	}
+
+	return changes
+}
+
+// CachedReleases returns updates for up to n most recent releases of the repo that were already seen by the poller,
+// newest first. Deleted releases are skipped. The feed is never fetched, so it's fast enough for inline queries.
+func CachedReleases(database db.Database, repo string, n int) ([]*types.Update, error) {
+	seen, err := database.GetSeenReleases(FeedURL(repo))
+	if err != nil {
+		return nil, err
+	}
+
+	latest := latestSeen(seen, n)
+	res := make([]*types.Update, 0, len(latest))
+	for _, r := range latest {
+		res = append(res, UpdateFromSeen(repo, "", r))
+	}
+	return res, nil
+}
+
+// latestSeen returns up to n releases that weren't deleted, newest first
+func latestSeen(seen []*db.SeenRelease, n int) []*db.SeenRelease {
+	res := make([]*db.SeenRelease, 0, len(seen))
+	for _, r := range seen {
+		if r.Status != db.ReleaseStatusDeleted {
+			res = append(res, r)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Updated.After(res[j].Updated)
+	})
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
@@ -0,0 +1,167 @@
+package feeds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Civil/github2telegram/db"
+	"github.com/Civil/github2telegram/types"
+)
+
+func testItem(tag, content string, updated time.Time) *gofeed.Item {
+	return &gofeed.Item{
+		GUID:          "tag:github.com,2008:Repository/1/" + tag,
+		Title:         tag,
+		Link:          "https://github.com/lomik/go-carbon/releases/tag/" + tag,
+		Content:       content,
+		UpdatedParsed: &updated,
+	}
+}
+
+func TestDetectChanges(t *testing.T) {
+	t0 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	old := testItem("v1.0.0", "initial release", t0)
+	edited := testItem("v1.1.0", "fixed typo", t0.Add(time.Hour))
+	renamed := testItem("v1.2.0", "renamed release", t0.Add(2*time.Hour))
+	fresh := testItem("v1.3.0", "new release", t0.Add(3*time.Hour))
+	withoutTime := &gofeed.Item{GUID: "no-time", Title: "v0.0.1", Content: "no timestamp"}
+
+	seen := []*db.SeenRelease{
+		newSeenRelease(old),
+		newSeenRelease(testItem("v1.1.0", "fixed tpyo", t0.Add(time.Hour))),
+		newSeenRelease(testItem("v1.2.0-wrong", "renamed release", t0.Add(2*time.Hour))),
+	}
+
+	changes := detectChanges(seen, chronological([]*gofeed.Item{fresh, renamed, edited, old, withoutTime}))
+
+	r := require.New(t)
+	r.Len(changes, 4)
+
+	r.Equal(types.NewRelease, changes[0].Type)
+	r.Equal("v0.0.1", changes[0].Tag)
+
+	r.Equal(types.DescriptionChange, changes[1].Type)
+	r.Equal("v1.1.0", changes[1].Tag)
+	r.Equal(seen[1], changes[1].Previous)
+
+	r.Equal(types.Retag, changes[2].Type)
+	r.Equal("v1.2.0", changes[2].Tag)
+	r.Equal("v1.2.0-wrong", changes[2].Previous.Tag)
+
+	r.Equal(types.NewRelease, changes[3].Type)
+	r.Equal("v1.3.0", changes[3].Tag)
+	r.Nil(changes[3].Previous)
+}
+
+func TestDetectDeletedAndYanked(t *testing.T) {
+	t0 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tooOld := testItem("v0.9.0", "pushed out of the feed", t0.Add(-time.Hour))
+	deleted := testItem("v1.0.0", "deleted release", t0.Add(time.Hour))
+	yanked := testItem("v1.1.0", "broken release", t0.Add(2*time.Hour))
+	current := testItem("v1.2.0", "current release", t0)
+
+	seen := []*db.SeenRelease{
+		newSeenRelease(tooOld),
+		newSeenRelease(deleted),
+		newSeenRelease(yanked),
+		newSeenRelease(current),
+	}
+
+	yanked.Title = "v1.1.0 (yanked)"
+	changes := detectChanges(seen, chronological([]*gofeed.Item{yanked, current}))
+
+	r := require.New(t)
+	r.Len(changes, 2)
+
+	r.Equal(types.Yanked, changes[0].Type)
+	r.Equal(db.ReleaseStatusYanked, changes[0].Seen.Status)
+
+	r.Equal(types.Deleted, changes[1].Type)
+	r.Equal("v1.0.0", changes[1].Tag)
+	r.Equal(db.ReleaseStatusDeleted, changes[1].Seen.Status)
+
+	// Nothing changes once deletion and yanking were recorded
+	seen[1] = changes[1].Seen
+	seen[2] = changes[0].Seen
+	r.Empty(detectChanges(seen, chronological([]*gofeed.Item{yanked, current})))
+}
+
+func TestIsYanked(t *testing.T) {
+	tests := []struct {
+		title string
+		want  bool
+	}{
+		{title: "v1.1.0 (yanked)", want: true},
+		{title: "[YANKED] v1.1.0", want: true},
+		{title: "v1.1.0 [retracted]", want: true},
+		{title: "Withdrawn: v1.1.0", want: true},
+		{title: "YANKED - v1.1.0", want: true},
+		{title: "v2.0: withdrawn deprecated API", want: false},
+		{title: "Retracted versions are skipped by go get", want: false},
+		{title: "v1.2.0", want: false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, isYanked(&gofeed.Item{Title: tt.title}), tt.title)
+	}
+}
+
+func TestVerifyDeleted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/v1.0.0") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t0 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	gone := testItem("v1.0.0", "deleted release", t0.Add(time.Hour))
+	gone.Link = server.URL + "/releases/tag/v1.0.0"
+	exists := testItem("v1.1.0", "pushed out release", t0.Add(2*time.Hour))
+	exists.Link = server.URL + "/releases/tag/v1.1.0"
+	current := testItem("v1.2.0", "current release", t0)
+
+	seen := []*db.SeenRelease{newSeenRelease(gone), newSeenRelease(exists), newSeenRelease(current)}
+	changes := detectChanges(seen, []*gofeed.Item{current})
+
+	r := require.New(t)
+	r.Len(changes, 2)
+
+	f := &Feed{stop: make(chan struct{})}
+	changes, checked := f.verifyDeleted(changes)
+	r.Len(changes, 1)
+	r.Equal("v1.0.0", changes[0].Tag)
+	r.Len(checked, 1)
+	r.Equal("v1.1.0", checked[0].Tag)
+	r.False(checked[0].CheckedAt.IsZero())
+	r.Empty(checked[0].Status)
+
+	// Release that was verified to exist is not checked again
+	seen[0] = changes[0].Seen
+	seen[1] = checked[0]
+	r.Empty(detectChanges(seen, []*gofeed.Item{current}))
+}
+
+func TestLatestSeen(t *testing.T) {
+	t0 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	seen := []*db.SeenRelease{
+		{Tag: "v1.0.0", Updated: t0},
+		{Tag: "v1.2.0", Updated: t0.Add(2 * time.Hour)},
+		{Tag: "v1.1.0", Updated: t0.Add(time.Hour), Status: db.ReleaseStatusYanked},
+		{Tag: "v1.3.0", Updated: t0.Add(3 * time.Hour), Status: db.ReleaseStatusDeleted},
+	}
+
+	var tags []string
+	for _, r := range latestSeen(seen, 2) {
+		tags = append(tags, r.Tag)
+	}
+	require.Equal(t, []string{"v1.2.0", "v1.1.0"}, tags)
+	require.Len(t, latestSeen(seen, 10), 3)
+}
@@ -9,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 
 	"github.com/Civil/github2telegram/configs"
 	"github.com/Civil/github2telegram/db"
@@ -26,8 +27,19 @@ func main() {
 	logger := zapwriter.Logger("main")
 
 	configFile := flag.String("c", "config.yaml", "config file (json)")
+	testRepo := flag.String("test", "", "check which of the recent releases of the repo would be announced by -regexp and exit")
+	testRegexp := flag.String("regexp", ".*", "filter regexp used by -test")
+	testTemplate := flag.String("template", "", "message template used by -test, default one if empty")
 	flag.Parse()
 
+	if *testRepo != "" {
+		err = dryRun(os.Stdout, *testRepo, *testRegexp, *testTemplate)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if *configFile != "" {
 		logger.Info("Will apply config from file",
 			zap.String("config_file", *configFile),
@@ -55,7 +67,7 @@ func main() {
 		}
 	}
 
-	logger.Debug("loaded config", zap.Any("config", configs.Config))
+	logger.Debug("loaded config", zap.Any("config", &configs.Config))
 
 	if configs.Config.DatabaseType != "sqlite3" {
 		logger.Fatal("unsupported database",
@@ -84,6 +96,7 @@ func main() {
 				telegram.WithListenAddress(cfg.WebhookListenAddress),
 				telegram.WithWebhookPath(cfg.WebhookPath),
 				telegram.WithWebhookURL(cfg.WebhookURL),
+				telegram.WithParseMode(cfg.ParseMode),
 			)
 			if err != nil {
 				logger.Fatal("Error initializing telegram endpoint",
@@ -123,6 +136,8 @@ func main() {
 			logger.Error("feedListDB Creation failed", zap.Error(err))
 			continue
 		}
+		f2.VersionFilter = f.VersionFilter
+		f2.Rules = f.Rules
 		feedsList = append(feedsList, f2)
 	}
 	logger.Debug("feedListDB Created", zap.Any("feeds", feedsListDB))
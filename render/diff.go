@@ -0,0 +1,63 @@
+package render
+
+import (
+	"strings"
+)
+
+// notesLines converts release notes to plain text and splits them into non-empty lines
+func notesLines(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(ParseNotes(content).Text(), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// diffNotes returns compact line diff of the release notes: added lines are prefixed with "+ ", removed with "- ".
+// Unchanged lines are omitted.
+func diffNotes(oldContent, newContent string) string {
+	a := notesLines(oldContent)
+	b := notesLines(newContent)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var res []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			res = append(res, "- "+a[i])
+			i++
+		default:
+			res = append(res, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		res = append(res, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		res = append(res, "+ "+b[j])
+	}
+
+	return strings.Join(res, "\n")
+}
@@ -0,0 +1,14 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffNotes(t *testing.T) {
+	oldNotes := "<ul><li>Fix crash</li><li>Improve logging</li></ul>"
+	newNotes := "<ul><li>Fix crash on startup</li><li>Improve logging</li><li>Add /semver command</li></ul>"
+
+	require.Equal(t, "- • Fix crash\n+ • Fix crash on startup\n+ • Add /semver command", diffNotes(oldNotes, newNotes))
+}
@@ -0,0 +1,134 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Format is a markup of the rendered message
+type Format int
+
+const (
+	FormatMarkdownV2 Format = iota
+	FormatHTML
+	FormatPlain
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatMarkdownV2:
+		return "markdownv2"
+	case FormatHTML:
+		return "html"
+	case FormatPlain:
+		return "plain"
+	}
+	return "unknown"
+}
+
+// ParseFormat parses format name, empty name means MarkdownV2
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "", "markdownv2", "markdown":
+		return FormatMarkdownV2, nil
+	case "html":
+		return FormatHTML, nil
+	case "plain", "text":
+		return FormatPlain, nil
+	}
+	return FormatMarkdownV2, fmt.Errorf("unknown format %q, supported: markdownv2, html, plain", s)
+}
+
+var (
+	// markdownV2Replacer escapes all characters that have special meaning in MarkdownV2
+	markdownV2Replacer = strings.NewReplacer(
+		"\\", "\\\\",
+		"_", "\\_",
+		"*", "\\*",
+		"[", "\\[",
+		"]", "\\]",
+		"(", "\\(",
+		")", "\\)",
+		"~", "\\~",
+		"`", "\\`",
+		">", "\\>",
+		"#", "\\#",
+		"+", "\\+",
+		"-", "\\-",
+		"=", "\\=",
+		"|", "\\|",
+		"{", "\\{",
+		"}", "\\}",
+		".", "\\.",
+		"!", "\\!",
+	)
+	markdownV2CodeReplacer = strings.NewReplacer(
+		"\\", "\\\\",
+		"`", "\\`",
+	)
+	markdownV2URLReplacer = strings.NewReplacer(
+		"\\", "\\\\",
+		")", "\\)",
+	)
+	htmlReplacer = strings.NewReplacer(
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		`"`, "&quot;",
+	)
+)
+
+// Escape escapes text, so it's shown as-is in the specified format
+func Escape(format Format, s string) string {
+	switch format {
+	case FormatMarkdownV2:
+		return markdownV2Replacer.Replace(s)
+	case FormatHTML:
+		return htmlReplacer.Replace(s)
+	}
+	return s
+}
+
+// EscapeCode escapes text that goes inside of inline code or code block
+func EscapeCode(format Format, s string) string {
+	switch format {
+	case FormatMarkdownV2:
+		return markdownV2CodeReplacer.Replace(s)
+	case FormatHTML:
+		return htmlReplacer.Replace(s)
+	}
+	return s
+}
+
+// EscapeURL escapes link target
+func EscapeURL(format Format, s string) string {
+	switch format {
+	case FormatMarkdownV2:
+		return markdownV2URLReplacer.Replace(s)
+	case FormatHTML:
+		return htmlReplacer.Replace(s)
+	}
+	return s
+}
+
+// Code formats text as inline code
+func Code(format Format, s string) string {
+	switch format {
+	case FormatMarkdownV2:
+		return "`" + EscapeCode(format, s) + "`"
+	case FormatHTML:
+		return "<code>" + EscapeCode(format, s) + "</code>"
+	}
+	return s
+}
+
+// CodeBlock formats text as preformatted block
+func CodeBlock(format Format, s string) string {
+	switch format {
+	case FormatMarkdownV2:
+		return "```\n" + EscapeCode(format, s) + "\n```"
+	case FormatHTML:
+		return "<pre>" + EscapeCode(format, s) + "</pre>"
+	}
+	return s
+}
@@ -0,0 +1,676 @@
+package render
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lunny/html2md"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type style uint8
+
+const (
+	styleBold style = 1 << iota
+	styleItalic
+	styleStrike
+	styleCode
+)
+
+// span is a piece of text with the same formatting
+type span struct {
+	text  string
+	style style
+	url   string
+}
+
+type blockKind int
+
+const (
+	blockParagraph blockKind = iota
+	blockHeading
+	blockListItem
+	blockCode
+	blockQuote
+)
+
+// block is a paragraph-level element of the notes
+type block struct {
+	kind  blockKind
+	spans []span
+	// marker of the list item, e.x. "•" or "2."
+	marker string
+	depth  int
+}
+
+func (b *block) text() string {
+	var sb strings.Builder
+	for _, s := range b.spans {
+		sb.WriteString(s.text)
+	}
+	return sb.String()
+}
+
+// Notes are release notes reduced to the formatting Telegram supports: headings, lists, quotes, code, links, bold,
+// italic and strikethrough text.
+type Notes struct {
+	blocks []*block
+}
+
+// ParseNotes parses release notes, which are either HTML (GitHub feeds) or markdown
+func ParseNotes(content string) *Notes {
+	b := &notesBuilder{notes: &Notes{}}
+	if looksLikeHTML(content) {
+		doc, err := html.Parse(strings.NewReader(content))
+		if err == nil {
+			b.walk(doc)
+			b.flush()
+			return b.notes
+		}
+	}
+	b.markdown(content)
+	return b.notes
+}
+
+var htmlTagRe = regexp.MustCompile(`</?[a-zA-Z][a-zA-Z0-9]*(\s[^>]*)?/?>`)
+
+func looksLikeHTML(content string) bool {
+	return htmlTagRe.MatchString(content)
+}
+
+type listState struct {
+	ordered bool
+	n       int
+}
+
+type notesBuilder struct {
+	notes   *Notes
+	current *block
+	style   style
+	url     string
+	lists   []*listState
+	quote   int
+	pre     int
+}
+
+func (b *notesBuilder) startBlock(kind blockKind) *block {
+	b.flush()
+	b.current = &block{kind: kind}
+	return b.current
+}
+
+func (b *notesBuilder) flush() {
+	if b.current == nil {
+		return
+	}
+	blk := b.current
+	b.current = nil
+
+	// Trailing whitespace is not visible, but it's counted by Telegram. Indentation of the code is kept
+	cutset := " \t\n"
+	if blk.kind == blockCode {
+		cutset = "\n"
+	}
+	for len(blk.spans) > 0 {
+		last := &blk.spans[len(blk.spans)-1]
+		last.text = strings.TrimRight(last.text, cutset)
+		if last.text != "" {
+			break
+		}
+		blk.spans = blk.spans[:len(blk.spans)-1]
+	}
+	if strings.TrimSpace(blk.text()) == "" {
+		return
+	}
+	b.notes.blocks = append(b.notes.blocks, blk)
+}
+
+var spacesRe = regexp.MustCompile(`\s+`)
+
+// text adds text to the current block, starting new paragraph if needed. Outside of preformatted text whitespace is
+// collapsed the same way browser does it.
+func (b *notesBuilder) text(s string) {
+	if b.pre == 0 {
+		s = spacesRe.ReplaceAllString(s, " ")
+	}
+	if b.current == nil {
+		if strings.TrimSpace(s) == "" {
+			return
+		}
+		kind := blockParagraph
+		if b.quote > 0 {
+			kind = blockQuote
+		}
+		b.current = &block{kind: kind}
+	}
+	if b.pre == 0 && b.atLineStart() {
+		s = strings.TrimLeft(s, " ")
+	}
+	b.raw(s)
+}
+
+func (b *notesBuilder) atLineStart() bool {
+	spans := b.current.spans
+	if len(spans) == 0 {
+		return true
+	}
+	last := spans[len(spans)-1].text
+	return strings.HasSuffix(last, " ") || strings.HasSuffix(last, "\n")
+}
+
+// raw adds text to the current block as-is
+func (b *notesBuilder) raw(s string) {
+	if s == "" {
+		return
+	}
+	if b.current == nil {
+		b.current = &block{kind: blockParagraph}
+	}
+	st := b.style
+	if b.current.kind == blockCode {
+		st = 0
+	}
+	spans := b.current.spans
+	if n := len(spans); n > 0 && spans[n-1].style == st && spans[n-1].url == b.url {
+		spans[n-1].text += s
+		return
+	}
+	b.current.spans = append(spans, span{text: s, style: st, url: b.url})
+}
+
+func (b *notesBuilder) styled(s style, f func()) {
+	old := b.style
+	b.style |= s
+	f()
+	b.style = old
+}
+
+func (b *notesBuilder) link(url string, f func()) {
+	old := b.url
+	b.url = url
+	f()
+	b.url = old
+}
+
+func (b *notesBuilder) walkChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.walk(c)
+	}
+}
+
+func (b *notesBuilder) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		b.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		b.walkChildren(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Svg, atom.Img, atom.Head:
+		return
+	case atom.Br:
+		if b.current != nil {
+			b.raw("\n")
+		}
+		return
+	case atom.P, atom.Div, atom.Table, atom.Tr, atom.Details, atom.Summary, atom.Hr, atom.Dl, atom.Dt, atom.Dd:
+		// Items of loose lists have their text wrapped into paragraphs
+		if b.current == nil || b.current.kind != blockListItem || len(b.current.spans) > 0 {
+			b.flush()
+		}
+		b.walkChildren(n)
+		b.flush()
+		return
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		b.startBlock(blockHeading)
+		b.walkChildren(n)
+		b.flush()
+		return
+	case atom.Ul, atom.Ol:
+		b.flush()
+		b.lists = append(b.lists, &listState{ordered: n.DataAtom == atom.Ol})
+		b.walkChildren(n)
+		b.lists = b.lists[:len(b.lists)-1]
+		b.flush()
+		return
+	case atom.Li:
+		blk := b.startBlock(blockListItem)
+		blk.marker = "•"
+		if len(b.lists) > 0 {
+			list := b.lists[len(b.lists)-1]
+			list.n++
+			if list.ordered {
+				blk.marker = strconv.Itoa(list.n) + "."
+			}
+			blk.depth = len(b.lists) - 1
+		}
+		b.walkChildren(n)
+		b.flush()
+		return
+	case atom.Pre:
+		b.startBlock(blockCode)
+		b.pre++
+		b.walkChildren(n)
+		b.pre--
+		b.flush()
+		return
+	case atom.Blockquote:
+		b.flush()
+		b.quote++
+		b.walkChildren(n)
+		b.quote--
+		b.flush()
+		return
+	case atom.Code, atom.Tt, atom.Kbd, atom.Samp:
+		b.styled(styleCode, func() { b.walkChildren(n) })
+		return
+	case atom.B, atom.Strong:
+		b.styled(styleBold, func() { b.walkChildren(n) })
+		return
+	case atom.I, atom.Em:
+		b.styled(styleItalic, func() { b.walkChildren(n) })
+		return
+	case atom.S, atom.Del, atom.Strike:
+		b.styled(styleStrike, func() { b.walkChildren(n) })
+		return
+	case atom.A:
+		href := attr(n, "href")
+		if b.url == "" && (strings.HasPrefix(href, "https://") || strings.HasPrefix(href, "http://")) {
+			b.link(href, func() { b.walkChildren(n) })
+			return
+		}
+	}
+	b.walkChildren(n)
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+var (
+	mdHeadingRe = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	mdListRe    = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	mdRuleRe    = regexp.MustCompile(`^([-*_]\s*){3,}$`)
+	mdLinkRe    = regexp.MustCompile(`^\[([^\]]*)\]\((https?://[^)\s]+)\)`)
+)
+
+// markdown parses commonly used subset of GitHub flavored markdown
+func (b *notesBuilder) markdown(content string) {
+	inCode := false
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			if inCode {
+				b.flush()
+			} else {
+				b.startBlock(blockCode)
+			}
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			if len(b.current.spans) > 0 {
+				b.raw("\n")
+			}
+			b.raw(line)
+			continue
+		}
+
+		switch {
+		case trimmed == "" || mdRuleRe.MatchString(trimmed):
+			b.flush()
+		case mdHeadingRe.MatchString(trimmed):
+			b.startBlock(blockHeading)
+			b.inline(mdHeadingRe.FindStringSubmatch(trimmed)[1])
+			b.flush()
+		case mdListRe.MatchString(line):
+			m := mdListRe.FindStringSubmatch(line)
+			blk := b.startBlock(blockListItem)
+			blk.depth = len(strings.ReplaceAll(m[1], "\t", "  ")) / 2
+			blk.marker = "•"
+			if m[2][0] >= '0' && m[2][0] <= '9' {
+				blk.marker = strings.TrimRight(m[2], ".)") + "."
+			}
+			b.inline(m[3])
+		case strings.HasPrefix(trimmed, ">"):
+			if b.current == nil || b.current.kind != blockQuote {
+				b.startBlock(blockQuote)
+			} else {
+				b.raw("\n")
+			}
+			b.inline(strings.TrimSpace(strings.TrimPrefix(trimmed, ">")))
+		default:
+			// Lines of the same paragraph are joined, as markdown does
+			if b.current != nil && b.current.kind != blockCode {
+				b.raw(" ")
+			}
+			b.inline(trimmed)
+		}
+	}
+	b.flush()
+}
+
+// inline parses inline markdown: code, bold, italic, strikethrough and links
+func (b *notesBuilder) inline(s string) {
+	prev := byte(' ')
+	for len(s) > 0 {
+		n := b.inlineToken(s, prev)
+		if n == 0 {
+			// Plain text till the next possible markup
+			n = strings.IndexAny(s[1:], "\\`*_~[")
+			if n < 0 {
+				n = len(s)
+			} else {
+				n++
+			}
+			b.text(s[:n])
+		}
+		prev = s[n-1]
+		s = s[n:]
+	}
+}
+
+// inlineToken parses markup at the beginning of the string and returns its length, 0 if there is no markup
+func (b *notesBuilder) inlineToken(s string, prev byte) int {
+	switch {
+	case s[0] == '\\' && len(s) > 1 && strings.IndexByte("\\`*_~[]()#+-.!>|{}", s[1]) != -1:
+		b.text(s[1:2])
+		return 2
+	case s[0] == '`':
+		end := strings.IndexByte(s[1:], '`')
+		if end <= 0 {
+			return 0
+		}
+		b.styled(styleCode, func() { b.raw(s[1 : end+1]) })
+		return end + 2
+	case strings.HasPrefix(s, "**") || strings.HasPrefix(s, "__") || strings.HasPrefix(s, "~~"):
+		end := strings.Index(s[2:], s[:2])
+		if end <= 0 || (s[0] == '_' && isWordChar(prev)) {
+			return 0
+		}
+		st := styleBold
+		if s[0] == '~' {
+			st = styleStrike
+		}
+		b.styled(st, func() { b.inline(s[2 : end+2]) })
+		return end + 4
+	case s[0] == '*' || s[0] == '_':
+		end := strings.IndexByte(s[1:], s[0])
+		if end <= 0 || s[1] == ' ' || (s[0] == '_' && isWordChar(prev)) {
+			return 0
+		}
+		if s[0] == '_' && end+2 < len(s) && isWordChar(s[end+2]) {
+			return 0
+		}
+		b.styled(styleItalic, func() { b.inline(s[1 : end+1]) })
+		return end + 2
+	case s[0] == '[':
+		m := mdLinkRe.FindStringSubmatch(s)
+		if m == nil {
+			return 0
+		}
+		b.link(m[2], func() { b.inline(m[1]) })
+		return len(m[0])
+	}
+	return 0
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// Truncate returns notes that have at most limit characters of visible text and whether anything was cut. Text is cut
+// only between characters, formatting of the remaining text is kept intact. Negative limit means no limit.
+func (n *Notes) Truncate(limit int) (*Notes, bool) {
+	head, rest := n.Cut(limit)
+	return head, rest != nil
+}
+
+// Cut splits notes into the head that has at most limit characters of visible text and the rest, which is nil if
+// nothing was cut. Negative limit means no limit.
+func (n *Notes) Cut(limit int) (*Notes, *Notes) {
+	return n.cut(limit, false)
+}
+
+// Chunks splits notes into parts that have at most limit characters of visible text each, preferring to split
+// between blocks
+func (n *Notes) Chunks(limit int) []*Notes {
+	var res []*Notes
+	rest := n
+	for rest != nil && len(rest.blocks) > 0 {
+		head, r := rest.cut(limit, true)
+		if len(head.blocks) == 0 {
+			// Nothing fits, limit is too small to make any progress
+			res = append(res, rest)
+			break
+		}
+		res = append(res, head)
+		rest = r
+	}
+	return res
+}
+
+// Len returns length of the visible text. Lengths of the notes are in UTF-16 code units, the way Telegram counts them,
+// so emoji and other characters outside of the basic plane count as two.
+func (n *Notes) Len() int {
+	l := 0
+	for i, blk := range n.blocks {
+		if i > 0 {
+			l++
+		}
+		l += blk.length()
+	}
+	return l
+}
+
+func (n *Notes) cut(limit int, wholeBlocks bool) (*Notes, *Notes) {
+	if limit < 0 {
+		return n, nil
+	}
+
+	head := &Notes{}
+	left := limit
+	for i, blk := range n.blocks {
+		if i > 0 {
+			// Blocks are separated by new line
+			left--
+		}
+		if size := blk.length(); size <= left {
+			head.blocks = append(head.blocks, blk)
+			left -= size
+			continue
+		}
+
+		rest := &Notes{}
+		if wholeBlocks && len(head.blocks) > 0 {
+			rest.blocks = append(rest.blocks, blk)
+		} else {
+			first, second := blk.cut(left)
+			if first != nil {
+				head.blocks = append(head.blocks, first)
+			}
+			if second != nil {
+				rest.blocks = append(rest.blocks, second)
+			}
+		}
+		rest.blocks = append(rest.blocks, n.blocks[i+1:]...)
+		return head, rest
+	}
+	return head, nil
+}
+
+func (b *block) length() int {
+	l := textLength(b.text())
+	if b.marker != "" {
+		l += textLength(b.marker) + 1
+	}
+	return l
+}
+
+// cut splits block, so the first part has at most limit characters. Parts that have no text are returned as nil.
+func (b *block) cut(limit int) (*block, *block) {
+	if b.marker != "" {
+		limit -= textLength(b.marker) + 1
+	}
+
+	first := &block{kind: b.kind, marker: b.marker, depth: b.depth}
+	// Continuation of the list item is not an item on its own
+	second := &block{kind: b.kind, depth: b.depth}
+	if b.kind == blockListItem {
+		second.kind = blockParagraph
+	}
+
+	for _, s := range b.spans {
+		l := textLength(s.text)
+		switch {
+		case limit <= 0:
+			second.spans = append(second.spans, s)
+		case l <= limit:
+			first.spans = append(first.spans, s)
+			limit -= l
+		default:
+			head := s
+			head.text = truncateText(s.text, limit)
+			first.spans = append(first.spans, head)
+			s.text = s.text[len(head.text):]
+			second.spans = append(second.spans, s)
+			limit = 0
+		}
+	}
+
+	if b.kind != blockCode {
+		if n := len(first.spans); n > 0 {
+			first.spans[n-1].text = strings.TrimRight(first.spans[n-1].text, " ")
+		}
+		if len(second.spans) > 0 {
+			second.spans[0].text = strings.TrimLeft(second.spans[0].text, " ")
+		}
+	}
+	return nonEmpty(first), nonEmpty(second)
+}
+
+func nonEmpty(b *block) *block {
+	if strings.TrimSpace(b.text()) == "" {
+		return nil
+	}
+	return b
+}
+
+// Format renders notes in the specified format
+func (n *Notes) Format(format Format) string {
+	var sb strings.Builder
+	for i, blk := range n.blocks {
+		if i > 0 {
+			sb.WriteString("\n")
+			if blk.kind == blockHeading {
+				sb.WriteString("\n")
+			}
+		}
+		switch blk.kind {
+		case blockCode:
+			sb.WriteString(CodeBlock(format, blk.text()))
+		case blockHeading:
+			sb.WriteString(formatSpans(format, blk.spans, styleBold))
+		case blockListItem:
+			sb.WriteString(strings.Repeat("  ", blk.depth) + Escape(format, blk.marker) + " " + formatSpans(format, blk.spans, 0))
+		case blockQuote:
+			sb.WriteString(formatQuote(format, formatSpans(format, blk.spans, 0)))
+		default:
+			sb.WriteString(formatSpans(format, blk.spans, 0))
+		}
+	}
+	return sb.String()
+}
+
+// Text returns notes as plain text
+func (n *Notes) Text() string {
+	return n.Format(FormatPlain)
+}
+
+// NotesMarkdown returns complete release notes in markdown
+func NotesMarkdown(content string) string {
+	if looksLikeHTML(content) {
+		return html2md.Convert(content)
+	}
+	return content
+}
+
+func formatQuote(format Format, s string) string {
+	switch format {
+	case FormatMarkdownV2:
+		return ">" + strings.ReplaceAll(s, "\n", "\n>")
+	case FormatHTML:
+		return "<blockquote>" + s + "</blockquote>"
+	}
+	return "> " + strings.ReplaceAll(s, "\n", "\n> ")
+}
+
+func formatSpans(format Format, spans []span, extra style) string {
+	var sb strings.Builder
+	for _, s := range spans {
+		if s.text == "" {
+			continue
+		}
+		s.style |= extra
+		formatted := formatSpan(format, s)
+		// "__" is always treated as underline, empty bold entity separates adjacent italic entities
+		if format == FormatMarkdownV2 && strings.HasSuffix(sb.String(), "_") && strings.HasPrefix(formatted, "_") {
+			sb.WriteString("**")
+		}
+		sb.WriteString(formatted)
+	}
+	return sb.String()
+}
+
+func formatSpan(format Format, s span) string {
+	switch format {
+	case FormatMarkdownV2:
+		t := Escape(format, s.text)
+		if s.style&styleCode != 0 {
+			t = "`" + EscapeCode(format, s.text) + "`"
+		}
+		if s.style&styleStrike != 0 {
+			t = "~" + t + "~"
+		}
+		if s.style&styleItalic != 0 {
+			t = "_" + t + "_"
+		}
+		if s.style&styleBold != 0 {
+			t = "*" + t + "*"
+		}
+		if s.url != "" {
+			t = "[" + t + "](" + EscapeURL(format, s.url) + ")"
+		}
+		return t
+	case FormatHTML:
+		t := Escape(format, s.text)
+		if s.style&styleCode != 0 {
+			t = "<code>" + t + "</code>"
+		}
+		if s.style&styleStrike != 0 {
+			t = "<s>" + t + "</s>"
+		}
+		if s.style&styleItalic != 0 {
+			t = "<i>" + t + "</i>"
+		}
+		if s.style&styleBold != 0 {
+			t = "<b>" + t + "</b>"
+		}
+		if s.url != "" {
+			t = `<a href="` + EscapeURL(format, s.url) + `">` + t + "</a>"
+		}
+		return t
+	}
+	return s.text
+}
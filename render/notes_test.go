@@ -0,0 +1,75 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const htmlNotes = `<h2>What's new</h2>
+<ul>
+<li>Fix <code>foo_bar</code> crash (<a href="https://github.com/a/b/pull/1">#1</a>)</li>
+<li>Nested<ul><li>inner <strong>bold</strong> <em>it</em></li></ul></li>
+</ul>
+<pre><code>go get x@v1.0.0
+</code></pre>
+<p>Thanks to @user_1 &amp; others!</p>`
+
+func TestNotesFormat(t *testing.T) {
+	r := require.New(t)
+	notes := ParseNotes(htmlNotes)
+
+	r.Equal("*What's new*\n"+
+		"• Fix `foo_bar` crash \\([\\#1](https://github.com/a/b/pull/1)\\)\n"+
+		"• Nested\n"+
+		"  • inner *bold* _it_\n"+
+		"```\ngo get x@v1.0.0\n```\n"+
+		"Thanks to @user\\_1 & others\\!", notes.Format(FormatMarkdownV2))
+
+	r.Equal("<b>What's new</b>\n"+
+		"• Fix <code>foo_bar</code> crash (<a href=\"https://github.com/a/b/pull/1\">#1</a>)\n"+
+		"• Nested\n"+
+		"  • inner <b>bold</b> <i>it</i>\n"+
+		"<pre>go get x@v1.0.0</pre>\n"+
+		"Thanks to @user_1 &amp; others!", notes.Format(FormatHTML))
+
+	r.Equal("What's new\n• Fix foo_bar crash (#1)\n• Nested\n  • inner bold it\ngo get x@v1.0.0\nThanks to @user_1 & others!",
+		notes.Text())
+}
+
+func TestNotesMarkdown(t *testing.T) {
+	md := "## Changes\n- **Breaking**: removed `foo`\n  * nested_item with snake_case\n\nSome *italic* and ~~old~~ text\ncontinued.\n\n> quoted\n\n```\ncode *here*\n```"
+
+	require.Equal(t, "*Changes*\n"+
+		"• *Breaking*: removed `foo`\n"+
+		"  • nested\\_item with snake\\_case\n"+
+		"Some _italic_ and ~old~ text continued\\.\n"+
+		">quoted\n"+
+		"```\ncode *here*\n```", ParseNotes(md).Format(FormatMarkdownV2))
+}
+
+func TestNotesTruncate(t *testing.T) {
+	r := require.New(t)
+
+	notes, truncated := ParseNotes("<p>Привет <b>мир</b>, как дела?</p>").Truncate(9)
+	r.True(truncated)
+	r.Equal("Привет *ми*", notes.Format(FormatMarkdownV2))
+
+	notes, truncated = ParseNotes(htmlNotes).Truncate(1000)
+	r.False(truncated)
+	r.Equal(ParseNotes(htmlNotes).Text(), notes.Text())
+}
+
+func TestNotesChunks(t *testing.T) {
+	r := require.New(t)
+	notes := ParseNotes("<p>first paragraph</p><ul><li>item one</li><li>item two</li></ul>")
+
+	head, rest := notes.Cut(21)
+	r.Equal("first paragraph\n• ite", head.Text())
+	r.Equal("m one\n• item two", rest.Text())
+
+	chunks := notes.Chunks(21)
+	r.Len(chunks, 2)
+	r.Equal("first paragraph", chunks[0].Text())
+	r.Equal("• item one\n• item two", chunks[1].Text())
+}
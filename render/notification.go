@@ -0,0 +1,183 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Civil/github2telegram/types"
+)
+
+const (
+	// DefaultNotesLimit is the default maximum length of release notes in the notification
+	DefaultNotesLimit = 250
+	// MaxMessageLength is the maximum length of the Telegram message
+	MaxMessageLength = 4096
+)
+
+// NotesMode defines what happens with release notes that are longer than the limit
+type NotesMode int
+
+const (
+	// NotesTruncate cuts release notes
+	NotesTruncate NotesMode = iota
+	// NotesSplit sends complete release notes, splitting them into as many messages as needed
+	NotesSplit
+	// NotesDocument cuts release notes, but attaches complete ones as a markdown document
+	NotesDocument
+)
+
+func (m NotesMode) String() string {
+	switch m {
+	case NotesTruncate:
+		return "truncate"
+	case NotesSplit:
+		return "split"
+	case NotesDocument:
+		return "document"
+	}
+	return "unknown"
+}
+
+// ParseNotesMode parses notes mode, empty string means NotesTruncate
+func ParseNotesMode(s string) (NotesMode, error) {
+	switch strings.ToLower(s) {
+	case "", "truncate":
+		return NotesTruncate, nil
+	case "split":
+		return NotesSplit, nil
+	case "document":
+		return NotesDocument, nil
+	}
+	return NotesTruncate, fmt.Errorf("unknown notes mode %q, supported: truncate, split, document", s)
+}
+
+// Options control how the notification is rendered
+type Options struct {
+	Format Format
+	// NotesLimit is the maximum length of release notes, 0 means DefaultNotesLimit and negative value means as much
+	// as fits into the message
+	NotesLimit int
+	NotesMode  NotesMode
+}
+
+// Message is a single message of the notification
+type Message struct {
+	Text string
+	// Fallback is a plain text version of the message
+	Fallback string
+}
+
+// Notification is a rendered notification
+type Notification struct {
+	// Messages to send, the first one is rendered from the template, others continue release notes
+	Messages []Message
+	// Document is complete release notes in markdown, set only in NotesDocument mode if notes were truncated
+	Document     string
+	DocumentName string
+}
+
+// textLength returns length of the text the way Telegram counts it, in UTF-16 code units
+func textLength(s string) int {
+	l := 0
+	for _, r := range s {
+		l += runeLength(r)
+	}
+	return l
+}
+
+// runeLength returns amount of UTF-16 code units the rune is encoded with
+func runeLength(r rune) int {
+	if r1, _ := utf16.EncodeRune(r); r1 != utf8.RuneError {
+		// Rune outside of the basic plane is encoded as a surrogate pair
+		return 2
+	}
+	return 1
+}
+
+// truncateText returns the longest prefix of the string that has at most limit UTF-16 code units, runes are never
+// split
+func truncateText(s string, limit int) string {
+	l := 0
+	for pos, r := range s {
+		l += runeLength(r)
+		if l > limit {
+			return s[:pos]
+		}
+	}
+	return s
+}
+
+// RenderNotification renders update into one or more messages that fit into Telegram's limits. Empty template means
+// default one for the format.
+func RenderNotification(update *types.Update, text string, opts Options) (*Notification, error) {
+	split := opts.NotesMode == NotesSplit
+	limit := opts.NotesLimit
+	if limit == 0 {
+		limit = DefaultNotesLimit
+	}
+	if split {
+		limit = -1
+	}
+
+	// Length of the formatted message is never less than Telegram counts after parsing the markup, so it's safe
+	// to shrink the notes by the difference
+	var res *rendered
+	var err error
+	for i := 0; i < 5; i++ {
+		res, err = renderUpdate(update, text, opts.Format, limit, split)
+		if err != nil {
+			return nil, err
+		}
+		over := textLength(res.text) - MaxMessageLength
+		if over <= 0 || res.notesLength == 0 {
+			break
+		}
+		// Reserve some space for the ellipsis
+		limit = max(res.notesLength-over-3, 0)
+	}
+
+	n := &Notification{
+		Messages: []Message{{Text: res.text}},
+	}
+	fallback, err := renderUpdate(update, text, FormatPlain, limit, split)
+	if err == nil {
+		n.Messages[0].Fallback = fallback.text
+	}
+
+	if res.rest != nil {
+		for _, chunk := range res.rest.Chunks(MaxMessageLength) {
+			n.Messages = append(n.Messages, Message{
+				Text:     chunk.Format(opts.Format),
+				Fallback: chunk.Text(),
+			})
+		}
+	}
+
+	if opts.NotesMode == NotesDocument && res.truncated {
+		n.Document = NotesMarkdown(update.Content)
+		n.DocumentName = documentName(update)
+	}
+
+	return n, nil
+}
+
+// documentName returns file name for the release notes, e.x. go-carbon-v1.2.0.md
+func documentName(update *types.Update) string {
+	name := update.Repo
+	if idx := strings.LastIndex(name, "/"); idx != -1 {
+		name = name[idx+1:]
+	}
+	if update.Tag != "" {
+		name += "-" + update.Tag
+	}
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	return name + ".md"
+}
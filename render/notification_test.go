@@ -0,0 +1,84 @@
+package render
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Civil/github2telegram/types"
+)
+
+func longNotes(items int) string {
+	var sb strings.Builder
+	sb.WriteString("<ul>")
+	for i := 0; i < items; i++ {
+		sb.WriteString("<li>Fix <code>crash</code> in the module number ")
+		sb.WriteString(strings.Repeat("ж", 50))
+		sb.WriteString("</li>")
+	}
+	sb.WriteString("</ul>")
+	return sb.String()
+}
+
+func TestRenderNotification(t *testing.T) {
+	r := require.New(t)
+	update := SampleUpdate("lomik/go-carbon", "all")
+	update.Content = longNotes(200)
+
+	n, err := RenderNotification(update, "", Options{Format: FormatMarkdownV2})
+	r.NoError(err)
+	r.Len(n.Messages, 1)
+	r.Contains(n.Messages[0].Text, "[More](")
+	r.Empty(n.Document)
+
+	n, err = RenderNotification(update, "", Options{Format: FormatMarkdownV2, NotesLimit: -1, NotesMode: NotesDocument})
+	r.NoError(err)
+	r.Len(n.Messages, 1)
+	r.LessOrEqual(textLength(n.Messages[0].Text), MaxMessageLength)
+	r.Equal("go-carbon-v1.2.0.md", n.DocumentName)
+	r.Contains(n.Document, "Fix `crash` in the module")
+
+	n, err = RenderNotification(update, "", Options{Format: FormatHTML, NotesMode: NotesSplit})
+	r.NoError(err)
+	r.Greater(len(n.Messages), 2)
+	total := 0
+	for _, m := range n.Messages {
+		// Telegram limits length of the text after parsing the markup
+		r.LessOrEqual(textLength(m.Fallback), MaxMessageLength)
+		r.NotEmpty(m.Fallback)
+		r.NotContains(m.Text, "More")
+		total += strings.Count(m.Fallback, "Fix crash")
+	}
+	r.Equal(200, total)
+
+	update.Type = types.Deleted
+	n, err = RenderNotification(update, "", Options{Format: FormatPlain, NotesMode: NotesSplit})
+	r.NoError(err)
+	r.Len(n.Messages, 1)
+}
+
+func TestRenderNotificationSplitEmoji(t *testing.T) {
+	r := require.New(t)
+	update := SampleUpdate("lomik/go-carbon", "all")
+	// Each rocket is a single rune, but Telegram counts it as two characters
+	paragraph := strings.Repeat("🚀", 3000)
+	update.Content = "<p>" + paragraph + "</p><ul><li>" + strings.Repeat("🎉 fix ", 1000) + "</li></ul>"
+
+	n, err := RenderNotification(update, "", Options{Format: FormatMarkdownV2, NotesMode: NotesSplit})
+	r.NoError(err)
+	r.Greater(len(n.Messages), 2)
+	rockets := 0
+	for _, m := range n.Messages {
+		r.LessOrEqual(textLength(m.Fallback), MaxMessageLength)
+		r.LessOrEqual(textLength(m.Text), MaxMessageLength)
+		r.True(utf8.ValidString(m.Text))
+		rockets += strings.Count(m.Fallback, "🚀")
+	}
+	r.Equal(3000, rockets)
+
+	r.Equal("a", truncateText("a🚀", 2))
+	r.Equal("a🚀", truncateText("a🚀", 3))
+	r.Equal("", truncateText("🚀", 1))
+}
@@ -0,0 +1,262 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/Civil/github2telegram/types"
+)
+
+// Asset is a file attached to the release
+type Asset struct {
+	Name string
+	URL  string
+}
+
+// Release is the data passed to the template. All strings are escaped according to the message format and notes are
+// already formatted, so they can be used as-is. Unescaped values and plain text notes are available through Raw.
+type Release struct {
+	// Type is one of new, retag, edit, deleted, yanked or summary
+	Type string
+	// Action is human-readable description of the change, e.x. "tagged"
+	Action string
+
+	Repo   string
+	Filter string
+	Tag    string
+	Title  string
+	Link   string
+	// URL is the link to the release escaped to be used as link target, e.x. [text]({{.URL}})
+	URL     string
+	Compare string
+	// Notes are formatted release notes, truncated if they are too long
+	Notes          string
+	NotesTruncated bool
+	// Diff is the code block with line diff of the release notes, set only if release description was changed
+	Diff string
+
+	// Channel is the main channel of the release, Channels are all of them
+	Channel  string
+	Channels []string
+	Assets   []Asset
+
+	// PreviousVersion is the tag of the last announced release or the old tag of re-tagged one
+	PreviousVersion string
+
+	// Releases are collapsed releases, set only for summary
+	Releases []*Release
+
+	Raw *Release
+}
+
+// Default templates announce a burst of releases collapsed into a single message as a list of their tags
+const defaultMarkdownTemplate = `{{if eq .Type "summary"}}{{.Repo}} {{.Action}} {{len .Releases}} releases since last check: ` +
+	"{{range $i, $r := .Releases}}{{if $i}}, {{end}}`{{$r.Tag}}`{{end}}" + `
+Latest: {{.Link}}{{else}}` +
+	"{{.Repo}} {{.Action}}: {{.Title}}" +
+	`{{if ne .Type "deleted"}}
+Link: {{.Link}}
+{{if .Diff}}Release notes changes:
+{{.Diff}}
+{{else if .Notes}}Release notes:
+{{.Notes}}
+{{end}}{{if .NotesTruncated}}[More]({{.URL}}){{end}}{{end}}{{end}}`
+
+const defaultHTMLTemplate = `{{if eq .Type "summary"}}<b>{{.Repo}}</b> {{.Action}} {{len .Releases}} releases since last check: ` +
+	`{{range $i, $r := .Releases}}{{if $i}}, {{end}}<code>{{$r.Tag}}</code>{{end}}
+Latest: {{.Link}}{{else}}` +
+	`<b>{{.Repo}}</b> {{.Action}}: {{.Title}}` +
+	`{{if ne .Type "deleted"}}
+Link: {{.Link}}
+{{if .Diff}}Release notes changes:
+{{.Diff}}
+{{else if .Notes}}Release notes:
+{{.Notes}}
+{{end}}{{if .NotesTruncated}}<a href="{{.URL}}">More</a>{{end}}{{end}}{{end}}`
+
+const defaultPlainTemplate = `{{if eq .Type "summary"}}{{.Repo}} {{.Action}} {{len .Releases}} releases since last check: ` +
+	`{{range $i, $r := .Releases}}{{if $i}}, {{end}}{{$r.Tag}}{{end}}
+Latest: {{.Link}}{{else}}` +
+	`{{.Repo}} {{.Action}}: {{.Title}}` +
+	`{{if ne .Type "deleted"}}
+Link: {{.Link}}
+{{if .Diff}}Release notes changes:
+{{.Diff}}
+{{else if .Notes}}Release notes:
+{{.Notes}}
+{{end}}{{end}}{{end}}`
+
+// DefaultTemplate returns template that is used when neither feed nor subscription have their own
+func DefaultTemplate(format Format) string {
+	switch format {
+	case FormatHTML:
+		return defaultHTMLTemplate
+	case FormatPlain:
+		return defaultPlainTemplate
+	}
+	return defaultMarkdownTemplate
+}
+
+// IsTemplate returns true if message pattern is a template. Feeds created by older versions have printf-style
+// patterns stored, they are ignored.
+func IsTemplate(pattern string) bool {
+	return strings.Contains(pattern, "{{")
+}
+
+// ParseTemplate parses template and checks that it can be rendered
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample, _ := newRelease(SampleUpdate("owner/repo", "default"), FormatPlain, DefaultNotesLimit, false)
+	err = tmpl.Execute(&bytes.Buffer{}, sample)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// SampleUpdate returns update that is used to preview templates when there are no real releases
+func SampleUpdate(repo, filter string) *types.Update {
+	return &types.Update{
+		Type:        types.NewRelease,
+		Repo:        repo,
+		Filter:      filter,
+		Tag:         "v1.2.0",
+		Title:       "Release 1.2.0",
+		Content:     "<ul><li>Add new feature</li><li>Fix crash on startup</li></ul>",
+		Link:        "https://github.com/" + repo + "/releases/tag/v1.2.0",
+		Channels:    []types.Channel{types.ChannelStable},
+		PreviousTag: "v1.1.0",
+		Assets: []types.Asset{
+			{Name: "release-1.2.0.tar.gz", URL: "https://github.com/" + repo + "/releases/download/v1.2.0/release-1.2.0.tar.gz"},
+		},
+	}
+}
+
+// Render renders update using the template and default notes limit. Empty template means default one for the format.
+func Render(update *types.Update, text string, format Format) (string, error) {
+	res, err := renderUpdate(update, text, format, DefaultNotesLimit, false)
+	if err != nil {
+		return "", err
+	}
+	return res.text, nil
+}
+
+// rendered is a message rendered from the template
+type rendered struct {
+	text string
+	// notesLength is the length of release notes or their diff included into the message
+	notesLength int
+	truncated   bool
+	// rest of the release notes that didn't fit into the message, set only if notes are split
+	rest *Notes
+}
+
+// renderUpdate renders update including at most limit characters of release notes, negative limit means all of them.
+// If split is true, notes that didn't fit are returned instead of being marked as truncated.
+func renderUpdate(update *types.Update, text string, format Format, limit int, split bool) (*rendered, error) {
+	if text == "" {
+		text = DefaultTemplate(format)
+	}
+	tmpl, err := template.New("message").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	release, res := newRelease(update, format, limit, split)
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, release)
+	if err != nil {
+		return nil, err
+	}
+
+	res.text = strings.TrimSpace(buf.String())
+	if res.text == "" {
+		return nil, fmt.Errorf("template rendered empty message")
+	}
+	return res, nil
+}
+
+func newRelease(update *types.Update, format Format, limit int, split bool) (*Release, *rendered) {
+	raw := &Release{
+		Type:            update.Type.Name(),
+		Action:          strings.Trim(update.Type.String(), " :"),
+		Repo:            update.Repo,
+		Filter:          update.Filter,
+		Tag:             update.Tag,
+		Title:           update.Title,
+		Link:            update.Link,
+		URL:             update.Link,
+		PreviousVersion: update.PreviousTag,
+	}
+	if update.PreviousTag != "" && update.Tag != "" {
+		raw.Compare = "https://github.com/" + update.Repo + "/compare/" + update.PreviousTag + "..." + update.Tag
+	}
+	for _, c := range update.Channels {
+		raw.Channels = append(raw.Channels, string(c))
+	}
+	if len(raw.Channels) > 0 {
+		raw.Channel = raw.Channels[0]
+	}
+	for _, a := range update.Assets {
+		raw.Assets = append(raw.Assets, Asset{Name: a.Name, URL: a.URL})
+	}
+
+	r := &Release{
+		Type:            raw.Type,
+		Action:          Escape(format, raw.Action),
+		Repo:            Escape(format, raw.Repo),
+		Filter:          Escape(format, raw.Filter),
+		Tag:             Escape(format, raw.Tag),
+		Title:           Escape(format, raw.Title),
+		Link:            Escape(format, raw.Link),
+		URL:             EscapeURL(format, raw.URL),
+		Compare:         Escape(format, raw.Compare),
+		Channel:         raw.Channel,
+		Channels:        raw.Channels,
+		PreviousVersion: Escape(format, raw.PreviousVersion),
+		Raw:             raw,
+	}
+	for _, a := range raw.Assets {
+		r.Assets = append(r.Assets, Asset{Name: Escape(format, a.Name), URL: EscapeURL(format, a.URL)})
+	}
+	for _, c := range update.Collapsed {
+		collapsed, _ := newRelease(c, format, limit, false)
+		r.Releases = append(r.Releases, collapsed)
+	}
+
+	res := &rendered{}
+	if update.Type == types.Deleted {
+		return r, res
+	}
+	if update.Type == types.DescriptionChange && update.PreviousContent != "" {
+		// Diff is never split, it's not that useful without the context anyway
+		diff := diffNotes(update.PreviousContent, update.Content)
+		if limit >= 0 && textLength(diff) > limit {
+			diff = truncateText(diff, limit) + "..."
+			res.truncated = true
+		}
+		res.notesLength = textLength(diff)
+		raw.Diff = diff
+		r.Diff = CodeBlock(format, diff)
+	} else {
+		notes, rest := ParseNotes(update.Content).Cut(limit)
+		res.notesLength = notes.Len()
+		raw.Notes = notes.Text()
+		r.Notes = notes.Format(format)
+		if rest != nil && split {
+			res.rest = rest
+		} else if rest != nil {
+			raw.Notes += "..."
+			r.Notes += Escape(format, "...")
+			res.truncated = true
+		}
+	}
+	raw.NotesTruncated = res.truncated
+	r.NotesTruncated = res.truncated
+	return r, res
+}
@@ -0,0 +1,114 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Civil/github2telegram/types"
+)
+
+func TestRender(t *testing.T) {
+	r := require.New(t)
+	update := SampleUpdate("lomik/go-carbon", "all")
+
+	msg, err := Render(update, "", FormatMarkdownV2)
+	r.NoError(err)
+	r.Equal("lomik/go\\-carbon tagged: Release 1\\.2\\.0\nLink: https://github\\.com/lomik/go\\-carbon/releases/tag/v1\\.2\\.0\n"+
+		"Release notes:\n• Add new feature\n• Fix crash on startup", msg)
+
+	msg, err = Render(update, `<b>{{.Repo}}</b> {{.Tag}} ({{.Channel}}, after {{.PreviousVersion}}){{range .Assets}} {{.Name}}{{end}}`, FormatHTML)
+	r.NoError(err)
+	r.Equal("<b>lomik/go-carbon</b> v1.2.0 (stable, after v1.1.0) release-1.2.0.tar.gz", msg)
+
+	update.Type = types.Deleted
+	msg, err = Render(update, "", FormatPlain)
+	r.NoError(err)
+	r.Equal("lomik/go-carbon release deleted: Release 1.2.0", msg)
+
+	_, err = Render(update, "{{.Unknown}}", FormatPlain)
+	r.Error(err)
+}
+
+func TestRenderUpdateTypes(t *testing.T) {
+	tests := []struct {
+		updateType types.UpdateType
+		markdown   string
+		plain      string
+	}{
+		{updateType: types.NewRelease, markdown: "lomik/go\\-carbon tagged: Release 1\\.2\\.0", plain: "lomik/go-carbon tagged: Release 1.2.0"},
+		{updateType: types.Retag, markdown: "lomik/go\\-carbon re\\-tagged: Release 1\\.2\\.0", plain: "lomik/go-carbon re-tagged: Release 1.2.0"},
+		{updateType: types.DescriptionChange, markdown: "lomik/go\\-carbon description changed: Release 1\\.2\\.0", plain: "lomik/go-carbon description changed: Release 1.2.0"},
+		{updateType: types.Deleted, markdown: "lomik/go\\-carbon release deleted: Release 1\\.2\\.0", plain: "lomik/go-carbon release deleted: Release 1.2.0"},
+		{updateType: types.Yanked, markdown: "lomik/go\\-carbon release yanked: Release 1\\.2\\.0", plain: "lomik/go-carbon release yanked: Release 1.2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.updateType.Name(), func(t *testing.T) {
+			update := SampleUpdate("lomik/go-carbon", "all")
+			update.Type = tt.updateType
+
+			msg, err := Render(update, "", FormatMarkdownV2)
+			require.NoError(t, err)
+			require.Equal(t, tt.markdown, strings.SplitN(msg, "\n", 2)[0])
+
+			msg, err = Render(update, "", FormatPlain)
+			require.NoError(t, err)
+			require.Equal(t, tt.plain, strings.SplitN(msg, "\n", 2)[0])
+
+			msg, err = Render(update, "{{.Raw.Action}}", FormatMarkdownV2)
+			require.NoError(t, err)
+			require.Equal(t, strings.Trim(tt.updateType.String(), " :"), msg)
+		})
+	}
+}
+
+func TestRenderSummary(t *testing.T) {
+	r := require.New(t)
+	summary := &types.Update{
+		Type:   types.ReleasesSummary,
+		Repo:   "lomik/go-carbon",
+		Filter: "all",
+		Tag:    "v1.3.0",
+		Title:  "Release 1.3.0",
+		Link:   "https://github.com/lomik/go-carbon/releases/tag/v1.3.0",
+	}
+	for _, tag := range []string{"v1.1.0", "v1.2.0", "v1.3.0"} {
+		release := SampleUpdate("lomik/go-carbon", "all")
+		release.Tag = tag
+		summary.Collapsed = append(summary.Collapsed, release)
+	}
+
+	msg, err := Render(summary, "", FormatMarkdownV2)
+	r.NoError(err)
+	r.Equal("lomik/go\\-carbon published 3 releases since last check: `v1\\.1\\.0`, `v1\\.2\\.0`, `v1\\.3\\.0`\n"+
+		"Latest: https://github\\.com/lomik/go\\-carbon/releases/tag/v1\\.3\\.0", msg)
+
+	msg, err = Render(summary, "", FormatPlain)
+	r.NoError(err)
+	r.Equal("lomik/go-carbon published 3 releases since last check: v1.1.0, v1.2.0, v1.3.0\n"+
+		"Latest: https://github.com/lomik/go-carbon/releases/tag/v1.3.0", msg)
+
+	// Custom templates apply to summaries as well
+	msg, err = Render(summary, `{{.Repo}} {{.Type}}:{{range .Releases}} {{.Tag}}{{end}}`, FormatHTML)
+	r.NoError(err)
+	r.Equal("lomik/go-carbon summary: v1.1.0 v1.2.0 v1.3.0", msg)
+
+	msg, err = Render(summary, `{{.Repo}} {{.Tag}}`, FormatPlain)
+	r.NoError(err)
+	r.Equal("lomik/go-carbon v1.3.0", msg)
+}
+
+func TestParseTemplate(t *testing.T) {
+	_, err := ParseTemplate("{{.Repo}} {{.Raw.Link}}")
+	require.NoError(t, err)
+
+	_, err = ParseTemplate("{{.Repo")
+	require.Error(t, err)
+
+	_, err = ParseTemplate("{{.Version}}")
+	require.Error(t, err)
+
+	require.True(t, IsTemplate("{{.Repo}}"))
+	require.False(t, IsTemplate("https://github.com/%v/releases/%v was tagged"))
+}
@@ -0,0 +1,181 @@
+package semver
+
+import (
+	"fmt"
+	"strings"
+)
+
+type operator int
+
+const (
+	opEqual operator = iota
+	opNotEqual
+	opGreater
+	opGreaterOrEqual
+	opLess
+	opLessOrEqual
+)
+
+type comparator struct {
+	op      operator
+	version *Version
+}
+
+func (c comparator) check(v *Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case opEqual:
+		return cmp == 0
+	case opNotEqual:
+		return cmp != 0
+	case opGreater:
+		return cmp > 0
+	case opGreaterOrEqual:
+		return cmp >= 0
+	case opLess:
+		return cmp < 0
+	case opLessOrEqual:
+		return cmp <= 0
+	}
+	return false
+}
+
+// Constraint is a set of version ranges, e.x. ">=2.0.0 <3 || ^1.5"
+//
+// Comparators within a range are separated by spaces or commas and all of them must match. Ranges are separated
+// by "||" and at least one of them must match. Supported operators are =, !=, >, >=, <, <=, ~ and ^. Versions can
+// be partial ("<3" is the same as "<3.0.0-0"), "1.2.x" or "1.2" without operator matches any 1.2 release.
+type Constraint struct {
+	raw    string
+	ranges [][]comparator
+}
+
+// ParseConstraint parses version constraint
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: strings.TrimSpace(s)}
+	if c.raw == "" {
+		return nil, fmt.Errorf("empty constraint")
+	}
+
+	for _, rangeStr := range strings.Split(c.raw, "||") {
+		fields := strings.FieldsFunc(rangeStr, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid constraint %q: empty range", c.raw)
+		}
+
+		var comparators []comparator
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			// Allow space between operator and version, e.x. ">= 2.0"
+			if strings.TrimLeft(field, "=!<>~^") == "" && i+1 < len(fields) {
+				field += fields[i+1]
+				i++
+			}
+			cmps, err := parseComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", c.raw, err)
+			}
+			comparators = append(comparators, cmps...)
+		}
+		c.ranges = append(c.ranges, comparators)
+	}
+
+	return c, nil
+}
+
+func parseComparator(s string) ([]comparator, error) {
+	opStr := s[:len(s)-len(strings.TrimLeft(s, "=!<>~^"))]
+	v, specified, err := parsePartial(s[len(opStr):], true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wildcard matches any version
+	if specified == 0 {
+		return []comparator{{op: opGreaterOrEqual, version: &Version{Prerelease: "0"}}}, nil
+	}
+
+	// upper is the lowest version that is out of range of partial version, e.x. 1.3.0-0 for 1.2
+	upper := func() *Version {
+		switch specified {
+		case 1:
+			return &Version{Major: v.Major + 1, Prerelease: "0"}
+		case 2:
+			return &Version{Major: v.Major, Minor: v.Minor + 1, Prerelease: "0"}
+		}
+		return nil
+	}
+
+	between := func(lower, upper *Version) []comparator {
+		res := []comparator{{op: opGreaterOrEqual, version: lower}}
+		if upper != nil {
+			res = append(res, comparator{op: opLess, version: upper})
+		}
+		return res
+	}
+
+	switch opStr {
+	case "", "=", "==":
+		if specified == 3 {
+			return []comparator{{op: opEqual, version: v}}, nil
+		}
+		return between(v, upper()), nil
+	case "!=":
+		return []comparator{{op: opNotEqual, version: v}}, nil
+	case ">":
+		if specified == 3 {
+			return []comparator{{op: opGreater, version: v}}, nil
+		}
+		return []comparator{{op: opGreaterOrEqual, version: upper()}}, nil
+	case ">=":
+		return []comparator{{op: opGreaterOrEqual, version: v}}, nil
+	case "<":
+		if specified == 3 || v.Prerelease != "" {
+			return []comparator{{op: opLess, version: v}}, nil
+		}
+		return []comparator{{op: opLess, version: &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch, Prerelease: "0"}}}, nil
+	case "<=":
+		if specified == 3 {
+			return []comparator{{op: opLessOrEqual, version: v}}, nil
+		}
+		return []comparator{{op: opLess, version: upper()}}, nil
+	case "~":
+		if specified == 3 {
+			return between(v, &Version{Major: v.Major, Minor: v.Minor + 1, Prerelease: "0"}), nil
+		}
+		return between(v, upper()), nil
+	case "^":
+		switch {
+		case v.Major != 0 || specified == 1:
+			return between(v, &Version{Major: v.Major + 1, Prerelease: "0"}), nil
+		case v.Minor != 0 || specified == 2:
+			return between(v, &Version{Minor: v.Minor + 1, Prerelease: "0"}), nil
+		}
+		return between(v, &Version{Patch: v.Patch + 1, Prerelease: "0"}), nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %q", opStr)
+}
+
+// Check returns true if version satisfies constraint
+func (c *Constraint) Check(v *Version) bool {
+	for _, r := range c.ranges {
+		matched := true
+		for _, cmp := range r {
+			if !cmp.check(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Constraint) String() string {
+	return c.raw
+}
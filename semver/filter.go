@@ -0,0 +1,169 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter decides if release should be announced based on its semantic version
+type Filter struct {
+	// Constraint that version must satisfy, nil means any version
+	Constraint *Constraint
+	// Bump is the minimal change compared to last notified version, e.x. BumpMinor skips patch releases
+	Bump BumpLevel
+	// ExcludePrereleases skips versions like 1.0.0-rc1
+	ExcludePrereleases bool
+	// OnlyGreater skips versions that are not greater than last notified version (e.x. backports to old branches)
+	OnlyGreater bool
+	// Prefix that tag must start with, e.x. "component/v". If empty, prefix is detected automatically
+	Prefix string
+}
+
+// ParseFilter parses filter specification, which is a space separated list of key=value options:
+//
+//	constraint=">=2.0.0 <3" bump=minor prereleases=exclude greater=true prefix=component/v
+//
+// Empty specification (or "off") results in nil filter.
+func ParseFilter(spec string) (*Filter, error) {
+	tokens, err := splitSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 || (len(tokens) == 1 && (tokens[0] == "off" || tokens[0] == "none")) {
+		return nil, nil
+	}
+
+	f := &Filter{}
+	for _, t := range tokens {
+		key, value, ok := strings.Cut(t, "=")
+		if !ok {
+			return nil, fmt.Errorf("option %q must be in key=value format", t)
+		}
+		err = f.Set(key, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// Set changes single option of the filter, options are the same as in specification parsed by ParseFilter
+func (f *Filter) Set(key, value string) error {
+	var err error
+	switch strings.ToLower(key) {
+	case "constraint", "version":
+		f.Constraint, err = ParseConstraint(value)
+	case "bump":
+		f.Bump, err = ParseBumpLevel(value)
+	case "prereleases":
+		switch strings.ToLower(value) {
+		case "include":
+			f.ExcludePrereleases = false
+		case "exclude":
+			f.ExcludePrereleases = true
+		default:
+			err = fmt.Errorf("prereleases must be either 'include' or 'exclude'")
+		}
+	case "greater":
+		f.OnlyGreater, err = strconv.ParseBool(value)
+	case "prefix":
+		f.Prefix = value
+	default:
+		err = fmt.Errorf("unknown option %q, supported: constraint, bump, prereleases, greater, prefix", key)
+	}
+	return err
+}
+
+// splitSpec splits specification by spaces, keeping double-quoted values together
+func splitSpec(spec string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuotes := false
+	hasToken := false
+	for _, r := range spec {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasToken = true
+		case !inQuotes && (r == ' ' || r == '\t' || r == '\n'):
+			if hasToken {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+				hasToken = false
+			}
+		default:
+			cur.WriteRune(r)
+			hasToken = true
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in %q", spec)
+	}
+	if hasToken {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+// String returns specification that can be parsed back by ParseFilter
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	var opts []string
+	if f.Constraint != nil {
+		opts = append(opts, "constraint="+strconv.Quote(f.Constraint.String()))
+	}
+	if f.Bump != BumpNone {
+		opts = append(opts, "bump="+f.Bump.String())
+	}
+	if f.ExcludePrereleases {
+		opts = append(opts, "prereleases=exclude")
+	}
+	if f.OnlyGreater {
+		opts = append(opts, "greater=true")
+	}
+	if f.Prefix != "" {
+		opts = append(opts, "prefix="+f.Prefix)
+	}
+	return strings.Join(opts, " ")
+}
+
+// Match checks if release tag passes the filter. lastTag is the tag of the last notified release and can be empty.
+// If release doesn't match, reason is returned.
+func (f *Filter) Match(tag, lastTag string) (bool, string) {
+	v, err := ParseTag(tag, f.Prefix)
+	if err != nil {
+		return false, "not a semantic version: " + err.Error()
+	}
+
+	if f.ExcludePrereleases && v.IsPrerelease() {
+		return false, "prerelease"
+	}
+
+	if f.Constraint != nil && !f.Constraint.Check(v) {
+		return false, "doesn't satisfy constraint " + f.Constraint.String()
+	}
+
+	if !f.OnlyGreater && f.Bump == BumpNone {
+		return true, ""
+	}
+
+	last, err := ParseTag(lastTag, f.Prefix)
+	if err != nil {
+		// Nothing was notified yet (or last tag is not a version), nothing to compare with
+		return true, ""
+	}
+
+	if f.OnlyGreater && v.Compare(last) <= 0 {
+		return false, "not greater than last notified version " + last.String()
+	}
+
+	if bump := Bump(last, v); bump < f.Bump {
+		return false, "only " + bump.String() + " change since " + last.String()
+	}
+
+	return true, ""
+}
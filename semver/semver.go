@@ -0,0 +1,249 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version, see https://semver.org
+type Version struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+	Build      string
+
+	// Original is the string version was parsed from (including prefix)
+	Original string
+}
+
+// Parse parses semantic version. Minor and patch components can be omitted, so "1.2" is treated as "1.2.0"
+func Parse(s string) (*Version, error) {
+	v, _, err := parsePartial(s, false)
+	return v, err
+}
+
+// ParseTag parses version from the release tag. If prefix is set, tag must start with it, otherwise everything up to
+// the last '/' and all non-digit characters before the version are stripped, so "v1.2.3", "component/v1.2.3" and
+// "release-1.2.3" are all parsed as 1.2.3
+func ParseTag(tag, prefix string) (*Version, error) {
+	s := tag
+	if prefix != "" {
+		if !strings.HasPrefix(s, prefix) {
+			return nil, fmt.Errorf("tag %q doesn't start with %q", tag, prefix)
+		}
+		s = s[len(prefix):]
+	} else {
+		if idx := strings.LastIndex(s, "/"); idx != -1 {
+			s = s[idx+1:]
+		}
+		if idx := strings.IndexFunc(s, isDigit); idx != -1 {
+			s = s[idx:]
+		}
+	}
+
+	v, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	v.Original = tag
+	return v, nil
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// parsePartial parses version and returns amount of numeric components that were specified explicitly. If wildcards
+// are allowed, components specified as 'x' or '*' are treated as omitted. Wildcards make sense only in constraints,
+// versions of the releases must be numeric.
+func parsePartial(s string, wildcards bool) (*Version, int, error) {
+	v := &Version{Original: s}
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	if s == "" {
+		return nil, 0, fmt.Errorf("empty version")
+	}
+
+	if idx := strings.IndexByte(s, '+'); idx != -1 {
+		v.Build = s[idx+1:]
+		s = s[:idx]
+		if v.Build == "" {
+			return nil, 0, fmt.Errorf("invalid version %q: empty build metadata", v.Original)
+		}
+	}
+	if idx := strings.IndexByte(s, '-'); idx != -1 {
+		v.Prerelease = s[idx+1:]
+		s = s[:idx]
+		if err := validatePrerelease(v.Prerelease); err != nil {
+			return nil, 0, fmt.Errorf("invalid version %q: %w", v.Original, err)
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, 0, fmt.Errorf("invalid version %q: too many components", v.Original)
+	}
+
+	specified := 0
+	dst := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		if wildcards && (p == "x" || p == "X" || p == "*") {
+			break
+		}
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid version %q: component %q is not a number", v.Original, p)
+		}
+		*dst[i] = n
+		specified++
+	}
+
+	return v, specified, nil
+}
+
+func validatePrerelease(s string) error {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return fmt.Errorf("empty prerelease identifier")
+		}
+	}
+	return nil
+}
+
+// String returns version in canonical form, without prefix
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// IsPrerelease returns true if version have prerelease part, e.x. 1.0.0-rc1
+func (v *Version) IsPrerelease() bool {
+	return v.Prerelease != ""
+}
+
+// Compare returns -1, 0 or 1 if v is lower, equal or greater than o. Build metadata is ignored
+func (v *Version) Compare(o *Version) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func comparePrerelease(a, b string) int {
+	// Version without prerelease have higher precedence
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	aIDs := strings.Split(a, ".")
+	bIDs := strings.Split(b, ".")
+	for i := 0; i < len(aIDs) && i < len(bIDs); i++ {
+		aNum, aErr := strconv.ParseUint(aIDs[i], 10, 64)
+		bNum, bErr := strconv.ParseUint(bIDs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareUint(aNum, bNum); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			// Numeric identifiers always have lower precedence
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aIDs[i], bIDs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return compareUint(uint64(len(aIDs)), uint64(len(bIDs)))
+}
+
+// BumpLevel describes which part of the version was changed between two releases
+type BumpLevel int
+
+const (
+	BumpNone BumpLevel = iota
+	BumpPrerelease
+	BumpPatch
+	BumpMinor
+	BumpMajor
+)
+
+func (l BumpLevel) String() string {
+	switch l {
+	case BumpNone:
+		return "any"
+	case BumpPrerelease:
+		return "prerelease"
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseBumpLevel parses bump level from its string representation
+func ParseBumpLevel(s string) (BumpLevel, error) {
+	switch strings.ToLower(s) {
+	case "", "any", "none":
+		return BumpNone, nil
+	case "prerelease":
+		return BumpPrerelease, nil
+	case "patch":
+		return BumpPatch, nil
+	case "minor":
+		return BumpMinor, nil
+	case "major":
+		return BumpMajor, nil
+	}
+	return BumpNone, fmt.Errorf("unknown bump level %q, supported: major, minor, patch, prerelease, any", s)
+}
+
+// Bump returns the most significant part that differs between two versions
+func Bump(from, to *Version) BumpLevel {
+	switch {
+	case from.Major != to.Major:
+		return BumpMajor
+	case from.Minor != to.Minor:
+		return BumpMinor
+	case from.Patch != to.Patch:
+		return BumpPatch
+	case from.Prerelease != to.Prerelease:
+		return BumpPrerelease
+	}
+	return BumpNone
+}
@@ -0,0 +1,116 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag    string
+		prefix string
+		want   string
+		err    bool
+	}{
+		{tag: "v1.2.3", want: "1.2.3"},
+		{tag: "1.2", want: "1.2.0"},
+		{tag: "component/v2.0.0-rc.1", want: "2.0.0-rc.1"},
+		{tag: "release-1.4.0+build5", want: "1.4.0+build5"},
+		{tag: "component/v2.0.0", prefix: "component/v", want: "2.0.0"},
+		{tag: "other/v2.0.0", prefix: "component/v", err: true},
+		{tag: "nightly", err: true},
+		// Wildcards are allowed only in constraints
+		{tag: "x", err: true},
+		{tag: "vx", err: true},
+		{tag: "*", err: true},
+		{tag: "v1.x", err: true},
+		{tag: "1.2.*", err: true},
+	}
+
+	for _, tt := range tests {
+		v, err := ParseTag(tt.tag, tt.prefix)
+		if tt.err {
+			require.Error(t, err, tt.tag)
+			continue
+		}
+		require.NoError(t, err, tt.tag)
+		require.Equal(t, tt.want, v.String(), tt.tag)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11",
+		"1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0",
+	}
+	for i := 1; i < len(ordered); i++ {
+		a, err := Parse(ordered[i-1])
+		require.NoError(t, err)
+		b, err := Parse(ordered[i])
+		require.NoError(t, err)
+		require.Equal(t, -1, a.Compare(b), "%s < %s", a, b)
+		require.Equal(t, 1, b.Compare(a), "%s > %s", b, a)
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		skips      []string
+	}{
+		{">=2.0.0 <3", []string{"2.0.0", "2.9.9"}, []string{"1.9.9", "3.0.0", "3.0.0-rc1"}},
+		{">=2.0.0,<3", []string{"2.5.0"}, []string{"3.1.0"}},
+		{"~1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0", "0.2.2"}},
+		{"1.x || >=3", []string{"1.5.0", "3.0.0"}, []string{"2.0.0"}},
+		{"1.2.*", []string{"1.2.0", "1.2.7"}, []string{"1.3.0", "1.1.9"}},
+		{">2", []string{"3.0.0"}, []string{"2.5.0"}},
+		{"!= 1.0.0", []string{"1.0.1"}, []string{"1.0.0"}},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		require.NoError(t, err, tt.constraint)
+		for _, s := range tt.matches {
+			require.True(t, c.Check(mustParse(t, s)), "%s should match %s", s, tt.constraint)
+		}
+		for _, s := range tt.skips {
+			require.False(t, c.Check(mustParse(t, s)), "%s shouldn't match %s", s, tt.constraint)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f, err := ParseFilter(`constraint=">=1.0 <3" bump=minor prereleases=exclude greater=true`)
+	require.NoError(t, err)
+	require.Equal(t, `constraint=">=1.0 <3" bump=minor prereleases=exclude greater=true`, f.String())
+
+	tests := []struct {
+		tag     string
+		lastTag string
+		matches bool
+	}{
+		{tag: "v1.2.0", lastTag: "", matches: true},
+		{tag: "v1.3.0", lastTag: "v1.2.0", matches: true},
+		{tag: "v1.2.1", lastTag: "v1.2.0", matches: false},
+		{tag: "v1.1.0", lastTag: "v1.2.0", matches: false},
+		{tag: "v1.4.0-rc1", lastTag: "v1.2.0", matches: false},
+		{tag: "v3.0.0", lastTag: "v1.2.0", matches: false},
+	}
+	for _, tt := range tests {
+		ok, reason := f.Match(tt.tag, tt.lastTag)
+		require.Equal(t, tt.matches, ok, "%s after %s: %s", tt.tag, tt.lastTag, reason)
+	}
+
+	f, err = ParseFilter("off")
+	require.NoError(t, err)
+	require.Nil(t, f)
+}
+
+func mustParse(t *testing.T, s string) *Version {
+	v, err := Parse(s)
+	require.NoError(t, err)
+	return v
+}
@@ -0,0 +1,64 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Channel is a kind of release, e.x. stable or prerelease. Subscriptions can choose which channels they receive
+type Channel string
+
+const (
+	ChannelStable     Channel = "stable"
+	ChannelPrerelease Channel = "prerelease"
+	ChannelDraft      Channel = "draft"
+	ChannelNightly    Channel = "nightly"
+	ChannelLTS        Channel = "lts"
+)
+
+var AllChannels = []Channel{ChannelStable, ChannelPrerelease, ChannelDraft, ChannelNightly, ChannelLTS}
+
+// ParseChannels parses comma separated list of channels
+func ParseChannels(s string) ([]Channel, error) {
+	var res []Channel
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			return AllChannels, nil
+		}
+		found := false
+		for _, c := range AllChannels {
+			if string(c) == name {
+				res = append(res, c)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown channel %q, supported: %s", name, JoinChannels(AllChannels))
+		}
+	}
+	return res, nil
+}
+
+// JoinChannels returns comma separated list of channels that can be parsed back by ParseChannels
+func JoinChannels(channels []Channel) string {
+	names := make([]string, 0, len(channels))
+	for _, c := range channels {
+		names = append(names, string(c))
+	}
+	return strings.Join(names, ",")
+}
+
+// HasChannel returns true if channel is in the list
+func HasChannel(channels []Channel, channel Channel) bool {
+	for _, c := range channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
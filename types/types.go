@@ -1,23 +1,20 @@
 package types
 
-import "strings"
-
 type NotificationMessage struct {
-	ChatID  int64
-	Message string
+	ChatID int64
+	// ThreadID is the forum topic message is sent to, 0 means general topic
+	ThreadID int
+	Message  string
+	// Fallback is a plain text version of the message, sent if formatting of the message is rejected
+	Fallback string
+	Silent   bool
+	// Buttons are attached to the message as inline keyboard, one row per slice
+	Buttons [][]Button
 }
 
-// TODO: Create a proper text to markdown converter
-var MdReplacer = strings.NewReplacer(
-	".", "\\.",
-	"-", "\\-",
-	"#", "\\#",
-	",", "\\,",
-	"(", "\\(",
-	")", "\\)",
-	"[", "\\[",
-	"]", "\\]",
-	"{", "\\{",
-	"}", "\\}",
-	"+", "\\+",
-)
+// Button is a button of inline keyboard, it either opens URL or sends callback data back to the bot
+type Button struct {
+	Text string
+	URL  string `json:",omitempty"`
+	Data string `json:",omitempty"`
+}
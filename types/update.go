@@ -0,0 +1,83 @@
+package types
+
+type UpdateType int
+
+const (
+	NewRelease UpdateType = iota
+	Retag
+	DescriptionChange
+	// ReleasesSummary is a single update for multiple releases published since the last check
+	ReleasesSummary
+	// Deleted release disappeared from the source
+	Deleted
+	// Yanked release was marked as yanked or retracted by maintainers
+	Yanked
+)
+
+func (t UpdateType) String() string {
+	switch t {
+	case NewRelease:
+		return " tagged: "
+	case Retag:
+		return " re-tagged: "
+	case DescriptionChange:
+		return " description changed: "
+	case ReleasesSummary:
+		return " published "
+	case Deleted:
+		return " release deleted: "
+	case Yanked:
+		return " release yanked: "
+	default:
+		return " (unhandled update type): "
+	}
+}
+
+// Name returns short machine-readable name of the update type
+func (t UpdateType) Name() string {
+	switch t {
+	case NewRelease:
+		return "new"
+	case Retag:
+		return "retag"
+	case DescriptionChange:
+		return "edit"
+	case ReleasesSummary:
+		return "summary"
+	case Deleted:
+		return "deleted"
+	case Yanked:
+		return "yanked"
+	default:
+		return "unknown"
+	}
+}
+
+// Asset is a file attached to the release
+type Asset struct {
+	Name string
+	URL  string
+}
+
+type Update struct {
+	Type   UpdateType
+	Repo   string
+	Filter string
+
+	Tag      string
+	Title    string
+	Content  string
+	Link     string
+	Channels []Channel
+	Assets   []Asset
+
+	// PreviousTag and PreviousContent describe the release before it was re-tagged or edited
+	PreviousTag     string
+	PreviousContent string
+
+	// Collapsed are updates that were combined into the ReleasesSummary
+	Collapsed []*Update
+
+	// Template is the message template of the feed, can be empty or a legacy pattern
+	Template string
+}